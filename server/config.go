@@ -2,15 +2,42 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/justintoman/npc-surprise/pkg/stream"
 )
 
 type Config struct {
 	DatabaseURL string
 	ApiKey      string
 	AdminKey    string
+	UploadDir   string
+	// let players see the public fields of characters other players are voicing
+	TableView bool
+	// comma separated in SESSION_SECRET, newest first. Old ones keep working until they're removed.
+	SessionSecrets []string
+	SessionTTL     time.Duration
+	// COOKIE_DOMAIN, COOKIE_SAMESITE (lax, strict or none) and COOKIE_SECURE
+	CookieDomain   string
+	CookieSameSite http.SameSite
+	CookieSecure   bool
+	// fly.io sets FLY_APP_NAME, its proxy terminates https in front of us
+	BehindProxy bool
+	// OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL, GMs can only use passwords without them
+	OidcIssuer       string
+	OidcClientId     string
+	OidcClientSecret string
+	OidcRedirectURL  string
+	// STREAM_QUEUE_SIZE messages a client can fall behind, STREAM_SLOW_CLIENTS is resync or disconnect
+	StreamQueueSize   int
+	StreamSlowClients stream.SlowClientPolicy
+	// STREAM_REPLAY_SIZE messages kept per player for reconnects
+	StreamReplaySize int
 }
 
 func LoadConfig() Config {
@@ -33,9 +60,88 @@ func LoadConfig() Config {
 		panic("ADMIN_KEY is not set")
 	}
 
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "./uploads"
+	}
+
+	tableView := os.Getenv("TABLE_VIEW") == "true"
+
+	// a random one would log everyone out whenever the machine stops
+	sessionSecrets := make([]string, 0)
+	for _, secret := range strings.Split(os.Getenv("SESSION_SECRET"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			sessionSecrets = append(sessionSecrets, secret)
+		}
+	}
+	if len(sessionSecrets) == 0 {
+		panic("SESSION_SECRET is not set")
+	}
+
+	sessionTTL := 30 * 24 * time.Hour
+	if ttl := os.Getenv("SESSION_TTL"); ttl != "" {
+		sessionTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			panic("SESSION_TTL is not a duration")
+		}
+	}
+
+	cookieSameSite := http.SameSiteLaxMode
+	switch strings.ToLower(os.Getenv("COOKIE_SAMESITE")) {
+	case "", "lax":
+	case "strict":
+		cookieSameSite = http.SameSiteStrictMode
+	case "none":
+		cookieSameSite = http.SameSiteNoneMode
+	default:
+		panic("COOKIE_SAMESITE must be lax, strict or none")
+	}
+
+	behindProxy := os.Getenv("FLY_APP_NAME") != ""
+
+	streamQueueSize := stream.DefaultQueueSize
+	if size := os.Getenv("STREAM_QUEUE_SIZE"); size != "" {
+		streamQueueSize, err = strconv.Atoi(size)
+		if err != nil || streamQueueSize <= 0 {
+			panic("STREAM_QUEUE_SIZE is not a positive number")
+		}
+	}
+
+	streamReplaySize := stream.DefaultReplaySize
+	if size := os.Getenv("STREAM_REPLAY_SIZE"); size != "" {
+		streamReplaySize, err = strconv.Atoi(size)
+		if err != nil || streamReplaySize <= 0 {
+			panic("STREAM_REPLAY_SIZE is not a positive number")
+		}
+	}
+
+	streamSlowClients := stream.SlowClientResync
+	switch strings.ToLower(os.Getenv("STREAM_SLOW_CLIENTS")) {
+	case "", "resync":
+	case "disconnect":
+		streamSlowClients = stream.SlowClientDisconnect
+	default:
+		panic("STREAM_SLOW_CLIENTS must be resync or disconnect")
+	}
+
 	return Config{
-		DatabaseURL: url,
-		ApiKey:      apiKey,
-		AdminKey:    adminKey,
+		DatabaseURL:       url,
+		ApiKey:            apiKey,
+		AdminKey:          adminKey,
+		UploadDir:         uploadDir,
+		TableView:         tableView,
+		SessionSecrets:    sessionSecrets,
+		SessionTTL:        sessionTTL,
+		CookieDomain:      os.Getenv("COOKIE_DOMAIN"),
+		CookieSameSite:    cookieSameSite,
+		CookieSecure:      os.Getenv("COOKIE_SECURE") == "true",
+		BehindProxy:       behindProxy,
+		OidcIssuer:        os.Getenv("OIDC_ISSUER"),
+		OidcClientId:      os.Getenv("OIDC_CLIENT_ID"),
+		OidcClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		OidcRedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		StreamQueueSize:   streamQueueSize,
+		StreamSlowClients: streamSlowClients,
+		StreamReplaySize:  streamReplaySize,
 	}
 }
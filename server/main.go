@@ -3,11 +3,36 @@ package main
 import (
 	"github.com/justintoman/npc-surprise/pkg/db"
 	"github.com/justintoman/npc-surprise/pkg/router"
+	"github.com/justintoman/npc-surprise/pkg/services"
+	"github.com/justintoman/npc-surprise/pkg/stream"
 )
 
 func main() {
 	config := LoadConfig()
 	db := db.New(config.DatabaseURL, config.ApiKey)
-	r := router.New(db, config.AdminKey)
+	r := router.New(db, router.Config{
+		AdminKey:       config.AdminKey,
+		UploadDir:      config.UploadDir,
+		TableView:      config.TableView,
+		SessionSecrets: config.SessionSecrets,
+		SessionTTL:     config.SessionTTL,
+		Cookies: router.CookieConfig{
+			Domain:      config.CookieDomain,
+			SameSite:    config.CookieSameSite,
+			Secure:      config.CookieSecure,
+			BehindProxy: config.BehindProxy,
+		},
+		Oidc: services.OidcConfig{
+			Issuer:       config.OidcIssuer,
+			ClientId:     config.OidcClientId,
+			ClientSecret: config.OidcClientSecret,
+			RedirectURL:  config.OidcRedirectURL,
+		},
+		Stream: stream.Config{
+			QueueSize:   config.StreamQueueSize,
+			SlowClients: config.StreamSlowClients,
+			ReplaySize:  config.StreamReplaySize,
+		},
+	})
 	r.Run() // listen and serve on 0.0.0.0:8080
 }
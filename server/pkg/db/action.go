@@ -10,6 +10,7 @@ import (
 type CreateActionPayload struct {
 	Content     string `json:"content" binding:"required"`
 	CharacterId int    `json:"characterId" binding:"required"`
+	Notes       string `json:"notes,omitempty"`
 }
 
 type Action struct {
@@ -17,6 +18,29 @@ type Action struct {
 	Content     string `json:"content" binding:"required"`
 	CharacterId int    `json:"characterId" binding:"required"`
 	Revealed    bool   `json:"revealed"`
+	// spectators can see it once it's revealed
+	Public bool `json:"public"`
+	// GM only, never part of PlayerAction
+	Notes string `json:"notes"`
+}
+
+// PlayerAction is the only shape of an action that goes to players and spectators
+type PlayerAction struct {
+	Id          int    `json:"id"`
+	Content     string `json:"content"`
+	CharacterId int    `json:"characterId"`
+	Revealed    bool   `json:"revealed"`
+	Public      bool   `json:"public"`
+}
+
+func (action Action) ForPlayer() PlayerAction {
+	return PlayerAction{
+		Id:          action.Id,
+		Content:     action.Content,
+		CharacterId: action.CharacterId,
+		Revealed:    action.Revealed,
+		Public:      action.Public,
+	}
 }
 
 type ActionTable struct {
@@ -44,6 +68,16 @@ func (db ActionTable) GetAllRevealed(characterId int) ([]Action, error) {
 	return actions, err
 }
 
+func (db ActionTable) Search(terms []string) ([]Action, error) {
+	query := selectAll(db.from())
+	query = filterByAnyTerm(query, []string{"content"}, terms)
+	query = orderById(query)
+	data, _, err := query.Execute()
+	actions := make([]Action, 0)
+	json.Unmarshal(data, &actions)
+	return actions, err
+}
+
 func (db ActionTable) Get(id int) (Action, error) {
 	query := selectAll(db.from())
 	query = filterById(query, id)
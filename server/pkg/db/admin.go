@@ -0,0 +1,159 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+type AdminRole string
+
+const (
+	// owners can do everything, including deleting things and managing other admins
+	AdminRoleOwner AdminRole = "owner"
+	// co-GMs run the game, they can reveal, hide and edit but not delete
+	AdminRoleCoGm AdminRole = "co-gm"
+	// viewers get the admin stream read-only
+	AdminRoleViewer AdminRole = "viewer"
+)
+
+// Can reports whether a role is allowed to do something that needs the required role
+func (role AdminRole) Can(required AdminRole) bool {
+	return role.rank() >= required.rank()
+}
+
+func (role AdminRole) rank() int {
+	switch role {
+	case AdminRoleOwner:
+		return 3
+	case AdminRoleCoGm:
+		return 2
+	case AdminRoleViewer:
+		return 1
+	}
+	return 0
+}
+
+type CreateAdminPayload struct {
+	Name string    `json:"name" binding:"required"`
+	Role AdminRole `json:"role" binding:"required,oneof=owner co-gm viewer"`
+	// the first OIDC login with this verified email is linked to the account
+	Email string `json:"email,omitempty"`
+}
+
+type Admin struct {
+	Id                 int `json:"id"`
+	CreateAdminPayload `json:",inline"`
+}
+
+// the password hash is only read back when logging in, it's never part of Admin
+type adminWithPassword struct {
+	Admin        `json:",inline"`
+	PasswordHash string `json:"passwordHash"`
+}
+
+type insertAdminPayload struct {
+	CreateAdminPayload `json:",inline"`
+	PasswordHash       string `json:"passwordHash"`
+}
+
+const adminColumns = "id,name,role,email"
+
+type AdminTable struct {
+	client *supabase.Client
+}
+
+func (db AdminTable) GetAll() ([]Admin, error) {
+	query := db.from().Select(adminColumns, "exact", false)
+	query = orderById(query)
+	data, _, err := query.Execute()
+	admins := make([]Admin, 0)
+	json.Unmarshal(data, &admins)
+	return admins, err
+}
+
+func (db AdminTable) Get(id int) (Admin, error) {
+	query := db.from().Select(adminColumns, "exact", false)
+	query = filterById(query, id)
+	data, _, err := query.Execute()
+	var admin Admin
+	json.Unmarshal(data, &admin)
+	return admin, err
+}
+
+// GetWithPasswordHash finds an admin by name for logging in
+func (db AdminTable) GetWithPasswordHash(name string) (Admin, string, error) {
+	query := db.from().Select(adminColumns+",passwordHash", "exact", false)
+	query = query.Filter("name", "eq", name).Single()
+	data, _, err := query.Execute()
+	var admin adminWithPassword
+	json.Unmarshal(data, &admin)
+	return admin.Admin, admin.PasswordHash, err
+}
+
+// GetByOidcSubject finds the admin an OIDC identity was linked to
+func (db AdminTable) GetByOidcSubject(subject string) (Admin, error) {
+	query := db.from().Select(adminColumns, "exact", false)
+	query = query.Filter("oidcSubject", "eq", subject).Single()
+	data, _, err := query.Execute()
+	var admin Admin
+	json.Unmarshal(data, &admin)
+	return admin, err
+}
+
+// GetUnlinkedByEmail finds an admin with the email that no OIDC identity has been linked to yet
+func (db AdminTable) GetUnlinkedByEmail(email string) (Admin, error) {
+	query := db.from().Select(adminColumns, "exact", false)
+	query = query.Filter("email", "eq", email).Is("oidcSubject", "null").Single()
+	data, _, err := query.Execute()
+	var admin Admin
+	json.Unmarshal(data, &admin)
+	return admin, err
+}
+
+func (db AdminTable) SetOidcSubject(id int, subject string) error {
+	query := db.from().Update(map[string]string{"oidcSubject": subject}, "minimal", "")
+	query = query.Filter("id", "eq", strconv.Itoa(id))
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db AdminTable) Create(payload CreateAdminPayload, passwordHash string) (Admin, error) {
+	query := insertSingle(db.from(), insertAdminPayload{
+		CreateAdminPayload: payload,
+		PasswordHash:       passwordHash,
+	})
+	data, _, err := query.Execute()
+	var result Admin
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db AdminTable) SetPasswordHash(id int, passwordHash string) error {
+	query := db.from().Update(map[string]string{"passwordHash": passwordHash}, "minimal", "")
+	query = query.Filter("id", "eq", strconv.Itoa(id))
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db AdminTable) SetRole(id int, role AdminRole) (Admin, error) {
+	query := db.from().Update(map[string]AdminRole{"role": role}, "representation", "")
+	query = query.Filter("id", "eq", strconv.Itoa(id)).Single()
+	data, _, err := query.Execute()
+	var result Admin
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db AdminTable) Delete(id int) error {
+	query := deleteSingle(db.from())
+	query = filterById(query, id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table AdminTable) from() *postgrest.QueryBuilder {
+	return table.client.From("admins")
+}
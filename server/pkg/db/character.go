@@ -17,6 +17,11 @@ type Character struct {
 	Age         string `json:"age,omitempty"`
 	Description string `json:"description,omitempty"`
 	Appearance  string `json:"appearance,omitempty"`
+	// url paths of the uploaded portrait and its thumbnail
+	Portrait          string `json:"portrait,omitempty"`
+	PortraitThumbnail string `json:"portraitThumbnail,omitempty"`
+	// GM only, never part of PlayerCharacter
+	Notes string `json:"notes"`
 }
 
 type CharacterWithActions struct {
@@ -24,6 +29,52 @@ type CharacterWithActions struct {
 	Actions   []Action `json:"actions"`
 }
 
+// PlayerCharacter is the only shape of a character that goes to players and spectators.
+// Fields are copied over one by one, so anything GM only like Notes can't leak by accident.
+type PlayerCharacter struct {
+	Id                int    `json:"id"`
+	Name              string `json:"name"`
+	PlayerId          *int   `json:"playerId"`
+	Race              string `json:"race,omitempty"`
+	Gender            string `json:"gender,omitempty"`
+	Age               string `json:"age,omitempty"`
+	Description       string `json:"description,omitempty"`
+	Appearance        string `json:"appearance,omitempty"`
+	Portrait          string `json:"portrait,omitempty"`
+	PortraitThumbnail string `json:"portraitThumbnail,omitempty"`
+}
+
+type PlayerCharacterWithActions struct {
+	PlayerCharacter `json:",inline"`
+	Actions         []PlayerAction `json:"actions"`
+}
+
+func (character Character) ForPlayer() PlayerCharacter {
+	return PlayerCharacter{
+		Id:                character.Id,
+		Name:              character.Name,
+		PlayerId:          character.PlayerId,
+		Race:              character.Race,
+		Gender:            character.Gender,
+		Age:               character.Age,
+		Description:       character.Description,
+		Appearance:        character.Appearance,
+		Portrait:          character.Portrait,
+		PortraitThumbnail: character.PortraitThumbnail,
+	}
+}
+
+func (character CharacterWithActions) ForPlayer() PlayerCharacterWithActions {
+	actions := make([]PlayerAction, len(character.Actions))
+	for i, action := range character.Actions {
+		actions[i] = action.ForPlayer()
+	}
+	return PlayerCharacterWithActions{
+		PlayerCharacter: character.Character.ForPlayer(),
+		Actions:         actions,
+	}
+}
+
 type CreateCharacterPayload struct {
 	Name        string `json:"name" binding:"required"`
 	Race        string `json:"race,omitempty"`
@@ -31,6 +82,7 @@ type CreateCharacterPayload struct {
 	Age         string `json:"age,omitempty"`
 	Description string `json:"description,omitempty"`
 	Appearance  string `json:"appearance,omitempty"`
+	Notes       string `json:"notes,omitempty"`
 }
 
 type CharacterReveleadFields struct {
@@ -41,6 +93,7 @@ type CharacterReveleadFields struct {
 	Age         bool `json:"age"`
 	Description bool `json:"description"`
 	Appearance  bool `json:"appearance"`
+	Portrait    bool `json:"portrait"`
 }
 
 type CharacterTable struct {
@@ -66,6 +119,18 @@ func (db CharacterTable) GetAllByPlayerId(id int) ([]Character, error) {
 	return characters, err
 }
 
+var characterSearchColumns = []string{"name", "description", "appearance"}
+
+func (db CharacterTable) Search(terms []string) ([]Character, error) {
+	query := selectAll(db.from())
+	query = filterByAnyTerm(query, characterSearchColumns, terms)
+	query = orderById(query)
+	data, _, err := query.Execute()
+	characters := make([]Character, 0)
+	json.Unmarshal(data, &characters)
+	return characters, err
+}
+
 func (db CharacterTable) Get(id int) (Character, error) {
 	query := selectAll(db.from())
 	query = filterById(query, id)
@@ -103,6 +168,12 @@ func (db CharacterTable) Create(character CreateCharacterPayload) (Character, Ch
 		return Character{}, CharacterReveleadFields{}, err
 	}
 
+	_, err = db.UpdatePublicFields(CharacterReveleadFields{CharacterId: result.Id})
+	if err != nil {
+		slog.Error("Error creating character_public_fields", "error", err)
+		return Character{}, CharacterReveleadFields{}, err
+	}
+
 	return result, fields, err
 }
 
@@ -134,6 +205,28 @@ func (db CharacterTable) UpdateRevealedFields(revealedFields CharacterReveleadFi
 	return result, err
 }
 
+// GetPublicFields uses the same shape as revealed fields, but marks what spectators may see.
+// Characters made before public fields existed don't have a row, which means nothing is public.
+func (db CharacterTable) GetPublicFields(characterId int) (CharacterReveleadFields, error) {
+	query := selectAll(db.fromPublicFields())
+	query = filterByCharacterId(query, characterId)
+	data, _, err := query.Execute()
+	publicFields := make([]CharacterReveleadFields, 0)
+	json.Unmarshal(data, &publicFields)
+	if len(publicFields) == 0 {
+		return CharacterReveleadFields{CharacterId: characterId}, err
+	}
+	return publicFields[0], err
+}
+
+func (db CharacterTable) UpdatePublicFields(publicFields CharacterReveleadFields) (CharacterReveleadFields, error) {
+	query := db.fromPublicFields().Insert(publicFields, true, "characterId", "", "exact").Single()
+	data, _, err := query.Execute()
+	var result CharacterReveleadFields
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
 func (db CharacterTable) Delete(id int) error {
 	query := deleteSingle(db.from())
 	query = filterById(query, id)
@@ -148,3 +241,7 @@ func (table CharacterTable) from() *postgrest.QueryBuilder {
 func (table CharacterTable) fromRevealedFields() *postgrest.QueryBuilder {
 	return table.client.From("character_revealed_fields")
 }
+
+func (table CharacterTable) fromPublicFields() *postgrest.QueryBuilder {
+	return table.client.From("character_public_fields")
+}
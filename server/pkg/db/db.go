@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
@@ -18,6 +19,15 @@ func New(url string, key string) Db {
 		Character: CharacterTable{client: client},
 		Action:    ActionTable{client: client},
 		Player:    PlayerTable{client: client},
+		Handout:   HandoutTable{client: client},
+		Variable:  VariableTable{client: client},
+		Rule:      RevealRuleTable{client: client},
+		Poll:      PollTable{client: client},
+		Score:     ScoreTable{client: client},
+		Admin:     AdminTable{client: client},
+		Session:   SessionTable{client: client},
+		ApiToken:  ApiTokenTable{client: client},
+		Setting:   SettingTable{client: client},
 	}
 	return db
 }
@@ -26,6 +36,15 @@ type Db struct {
 	Character CharacterTable
 	Action    ActionTable
 	Player    PlayerTable
+	Handout   HandoutTable
+	Variable  VariableTable
+	Rule      RevealRuleTable
+	Poll      PollTable
+	Score     ScoreTable
+	Admin     AdminTable
+	Session   SessionTable
+	ApiToken  ApiTokenTable
+	Setting   SettingTable
 }
 
 func filterById(filterBuilder *postgrest.FilterBuilder, id int) *postgrest.FilterBuilder {
@@ -40,6 +59,18 @@ func filterByPlayerId(filterBuilder *postgrest.FilterBuilder, playerId int) *pos
 	return filterBuilder.Filter("playerId", "eq", strconv.Itoa(playerId))
 }
 
+// filterByAnyTerm matches rows where any of the columns contains any of the terms.
+// Terms are expected to be plain words, they are not escaped.
+func filterByAnyTerm(filterBuilder *postgrest.FilterBuilder, columns []string, terms []string) *postgrest.FilterBuilder {
+	filters := make([]string, 0, len(columns)*len(terms))
+	for _, column := range columns {
+		for _, term := range terms {
+			filters = append(filters, fmt.Sprintf("%s.ilike.*%s*", column, term))
+		}
+	}
+	return filterBuilder.Or(strings.Join(filters, ","), "")
+}
+
 func selectAll(queryBuilder *postgrest.QueryBuilder) *postgrest.FilterBuilder {
 	return queryBuilder.Select("*", "exact", false)
 }
@@ -57,3 +88,9 @@ func orderById(queryBuilder *postgrest.FilterBuilder) *postgrest.FilterBuilder {
 		Ascending: true,
 	})
 }
+
+// IsUniqueViolation is true when an insert hit a unique constraint.
+// postgrest-go only hands back the postgres error code in the message, like "(23505) duplicate key value ...".
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "(23505)")
+}
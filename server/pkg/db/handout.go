@@ -0,0 +1,88 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+type CreateHandoutPayload struct {
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	ContentType string `json:"contentType"`
+}
+
+type Handout struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	ContentType string `json:"contentType"`
+	// revealed to the whole table
+	Everyone bool `json:"everyone"`
+	// revealed to individual players, cleared before sending to players
+	PlayerIds []int `json:"playerIds"`
+}
+
+type HandoutTable struct {
+	client *supabase.Client
+}
+
+func (db HandoutTable) GetAll() ([]Handout, error) {
+	query := selectAll(db.from())
+	query = orderById(query)
+	data, _, err := query.Execute()
+	handouts := make([]Handout, 0)
+	json.Unmarshal(data, &handouts)
+	return handouts, err
+}
+
+func (db HandoutTable) GetAllRevealed(playerId int) ([]Handout, error) {
+	query := selectAll(db.from())
+	query = query.Or("everyone.eq.true,playerIds.cs.{"+strconv.Itoa(playerId)+"}", "")
+	query = orderById(query)
+	data, _, err := query.Execute()
+	handouts := make([]Handout, 0)
+	json.Unmarshal(data, &handouts)
+	return handouts, err
+}
+
+func (db HandoutTable) Get(id int) (Handout, error) {
+	query := selectAll(db.from())
+	query = filterById(query, id)
+	data, _, err := query.Execute()
+	var handout Handout
+	json.Unmarshal(data, &handout)
+	return handout, err
+}
+
+func (db HandoutTable) Create(payload CreateHandoutPayload) (Handout, error) {
+	query := insertSingle(db.from(), payload)
+	data, _, err := query.Execute()
+	var result Handout
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db HandoutTable) Update(handout Handout) (Handout, error) {
+	if handout.PlayerIds == nil {
+		handout.PlayerIds = make([]int, 0)
+	}
+	query := insertSingle(db.from(), handout)
+	data, _, err := query.Execute()
+	var result Handout
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db HandoutTable) Delete(id int) error {
+	query := deleteSingle(db.from())
+	query = filterById(query, id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table HandoutTable) from() *postgrest.QueryBuilder {
+	return table.client.From("handouts")
+}
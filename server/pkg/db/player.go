@@ -8,13 +8,30 @@ import (
 	"github.com/supabase-community/supabase-go"
 )
 
+type PlayerStatus string
+
+const (
+	// waiting in the lobby for the GM
+	PlayerStatusPending  PlayerStatus = "pending"
+	PlayerStatusApproved PlayerStatus = "approved"
+	PlayerStatusRejected PlayerStatus = "rejected"
+)
+
 type CreatePlayerPayload struct {
-	Name string `json:"name"`
+	Name   string       `json:"name"`
+	Status PlayerStatus `json:"status"`
 }
 
 type Player struct {
 	Id   int    `json:"id"`
 	Name string `json:"name"`
+	// left out of updates that don't change it, players from before the lobby have none
+	Status PlayerStatus `json:"status,omitempty"`
+}
+
+// IsApproved reports whether the player made it out of the lobby
+func (player Player) IsApproved() bool {
+	return player.Status == PlayerStatusApproved || player.Status == ""
 }
 
 type PlayerTable struct {
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+const (
+	PollAudiencePlayers    = "players"
+	PollAudienceSpectators = "spectators"
+	PollAudienceEveryone   = "everyone"
+)
+
+type CreatePollPayload struct {
+	Question string   `json:"question" binding:"required"`
+	Options  []string `json:"options" binding:"required,min=2"`
+	Audience string   `json:"audience" binding:"required,oneof=players spectators everyone"`
+}
+
+type Poll struct {
+	Id       int      `json:"id"`
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	Audience string   `json:"audience"`
+	Closed   bool     `json:"closed"`
+	// votes per option, only filled in for the admin or once the poll is closed
+	Tally []int `json:"tally"`
+}
+
+type PollVote struct {
+	PollId int `json:"pollId"`
+	// player:<id> or spectator:<key>, poll_votes has a unique ("pollId", voter) constraint
+	Voter  string `json:"voter"`
+	Option int    `json:"option"`
+}
+
+type PollTable struct {
+	client *supabase.Client
+}
+
+func (db PollTable) GetAll() ([]Poll, error) {
+	query := selectAll(db.from())
+	query = orderById(query)
+	data, _, err := query.Execute()
+	polls := make([]Poll, 0)
+	json.Unmarshal(data, &polls)
+	return polls, err
+}
+
+func (db PollTable) GetAllOpen() ([]Poll, error) {
+	query := selectAll(db.from())
+	query = query.Filter("closed", "eq", "false")
+	query = orderById(query)
+	data, _, err := query.Execute()
+	polls := make([]Poll, 0)
+	json.Unmarshal(data, &polls)
+	return polls, err
+}
+
+func (db PollTable) Get(id int) (Poll, error) {
+	query := selectAll(db.from())
+	query = filterById(query, id)
+	data, _, err := query.Execute()
+	var poll Poll
+	json.Unmarshal(data, &poll)
+	return poll, err
+}
+
+func (db PollTable) Create(payload CreatePollPayload) (Poll, error) {
+	query := insertSingle(db.from(), payload)
+	data, _, err := query.Execute()
+	var result Poll
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db PollTable) Update(poll Poll) (Poll, error) {
+	query := insertSingle(db.from(), poll)
+	data, _, err := query.Execute()
+	var result Poll
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db PollTable) GetVotes(pollId int) ([]PollVote, error) {
+	query := selectAll(db.fromVotes())
+	query = query.Filter("pollId", "eq", strconv.Itoa(pollId))
+	data, _, err := query.Execute()
+	votes := make([]PollVote, 0)
+	json.Unmarshal(data, &votes)
+	return votes, err
+}
+
+func (db PollTable) CreateVote(vote PollVote) (PollVote, error) {
+	query := db.fromVotes().Insert(vote, false, "", "", "exact").Single()
+	data, _, err := query.Execute()
+	var result PollVote
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (table PollTable) from() *postgrest.QueryBuilder {
+	return table.client.From("polls")
+}
+
+func (table PollTable) fromVotes() *postgrest.QueryBuilder {
+	return table.client.From("poll_votes")
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+// A rule is triggered by either an action or a character field being revealed
+// and reveals either another action or another character field.
+type CreateRevealRulePayload struct {
+	TriggerActionId    *int   `json:"triggerActionId"`
+	TriggerCharacterId *int   `json:"triggerCharacterId"`
+	TriggerField       string `json:"triggerField,omitempty"`
+	EffectActionId     *int   `json:"effectActionId"`
+	EffectCharacterId  *int   `json:"effectCharacterId"`
+	EffectField        string `json:"effectField,omitempty"`
+	DelaySeconds       int    `json:"delaySeconds"`
+}
+
+type RevealRule struct {
+	Id                      int `json:"id"`
+	CreateRevealRulePayload `json:",inline"`
+}
+
+type RevealRuleTable struct {
+	client *supabase.Client
+}
+
+func (db RevealRuleTable) GetAll() ([]RevealRule, error) {
+	query := selectAll(db.from())
+	query = orderById(query)
+	data, _, err := query.Execute()
+	rules := make([]RevealRule, 0)
+	json.Unmarshal(data, &rules)
+	return rules, err
+}
+
+func (db RevealRuleTable) Create(rule CreateRevealRulePayload) (RevealRule, error) {
+	query := insertSingle(db.from(), rule)
+	data, _, err := query.Execute()
+	var result RevealRule
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db RevealRuleTable) Delete(id int) error {
+	query := deleteSingle(db.from())
+	query = filterById(query, id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table RevealRuleTable) from() *postgrest.QueryBuilder {
+	return table.client.From("reveal_rules")
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+type CreateScoreEntryPayload struct {
+	PlayerId int    `json:"playerId" binding:"required,gt=0"`
+	Points   int    `json:"points" binding:"required"`
+	Reason   string `json:"reason" binding:"required"`
+	ActionId *int   `json:"actionId"`
+}
+
+// ScoreEntry is a single award or deduction, totals are the sum of the entries that aren't archived
+type ScoreEntry struct {
+	Id                      int `json:"id"`
+	CreateScoreEntryPayload `json:",inline"`
+	// archived entries are from a previous session
+	Archived bool `json:"archived"`
+}
+
+type ScoreTable struct {
+	client *supabase.Client
+}
+
+func (db ScoreTable) GetAllCurrent() ([]ScoreEntry, error) {
+	query := selectAll(db.from())
+	query = query.Filter("archived", "eq", "false")
+	query = orderById(query)
+	data, _, err := query.Execute()
+	entries := make([]ScoreEntry, 0)
+	json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func (db ScoreTable) Create(payload CreateScoreEntryPayload) (ScoreEntry, error) {
+	query := insertSingle(db.from(), payload)
+	data, _, err := query.Execute()
+	var result ScoreEntry
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+// Archive starts a new session, the old entries are kept for the recap
+func (db ScoreTable) Archive() error {
+	query := db.from().Update(map[string]bool{"archived": true}, "minimal", "")
+	query = query.Filter("archived", "eq", "false")
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table ScoreTable) from() *postgrest.QueryBuilder {
+	return table.client.From("score_entries")
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+// Session is a logged in device. Deleting it logs the device out, even though its cookie is still signed.
+type Session struct {
+	Id string `json:"id"`
+	// player id or admin stream id
+	PlayerId int `json:"playerId"`
+	// unix seconds
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+type SessionTable struct {
+	client *supabase.Client
+}
+
+func (db SessionTable) Get(id string) (Session, error) {
+	query := selectAll(db.from())
+	query = query.Filter("id", "eq", id).Single()
+	data, _, err := query.Execute()
+	var session Session
+	json.Unmarshal(data, &session)
+	return session, err
+}
+
+func (db SessionTable) Create(session Session) (Session, error) {
+	query := insertSingle(db.from(), session)
+	data, _, err := query.Execute()
+	var result Session
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db SessionTable) SetExpiresAt(id string, expiresAt int64) error {
+	query := db.from().Update(map[string]int64{"expiresAt": expiresAt}, "minimal", "")
+	query = query.Filter("id", "eq", id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db SessionTable) Delete(id string) error {
+	query := db.from().Delete("minimal", "")
+	query = query.Filter("id", "eq", id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db SessionTable) DeleteAllForPlayer(playerId int) error {
+	query := db.from().Delete("minimal", "")
+	query = filterByPlayerId(query, playerId)
+	_, _, err := query.Execute()
+	return err
+}
+
+// DeleteAllPlayers logs out every player, admins and their negative ids are left alone
+func (db SessionTable) DeleteAllPlayers() error {
+	query := db.from().Delete("minimal", "")
+	query = query.Filter("playerId", "gt", "0")
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db SessionTable) DeleteExpired(now int64) error {
+	query := db.from().Delete("minimal", "")
+	query = query.Filter("expiresAt", "lt", strconv.FormatInt(now, 10))
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table SessionTable) from() *postgrest.QueryBuilder {
+	return table.client.From("sessions")
+}
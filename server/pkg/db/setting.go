@@ -0,0 +1,44 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+// Setting is server state that has to outlive a restart, like the lobby's join code
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type SettingTable struct {
+	client *supabase.Client
+}
+
+// Get is the value of a setting, empty if it was never set
+func (db SettingTable) Get(key string) (string, error) {
+	query := selectAll(db.from())
+	query = query.Filter("key", "eq", key)
+	data, _, err := query.Execute()
+	settings := make([]Setting, 0)
+	json.Unmarshal(data, &settings)
+	if len(settings) == 0 {
+		return "", err
+	}
+	return settings[0].Value, err
+}
+
+// Set creates or replaces the setting with the same key
+func (db SettingTable) Set(setting Setting) (Setting, error) {
+	query := db.from().Insert(setting, true, "key", "", "exact").Single()
+	data, _, err := query.Execute()
+	var result Setting
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (table SettingTable) from() *postgrest.QueryBuilder {
+	return table.client.From("settings")
+}
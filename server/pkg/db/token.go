@@ -0,0 +1,106 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+type ApiTokenScope string
+
+const (
+	// GET anything an admin can see
+	ApiTokenScopeRead ApiTokenScope = "read"
+	// reveal and hide actions, fields and handouts
+	ApiTokenScopeReveal ApiTokenScope = "reveal"
+	// create, change and delete everything else
+	ApiTokenScopeEdit ApiTokenScope = "edit"
+)
+
+type CreateApiTokenPayload struct {
+	Name   string          `json:"name" binding:"required"`
+	Scopes []ApiTokenScope `json:"scopes" binding:"required,dive,oneof=read reveal edit"`
+	// unix seconds, never expires without it
+	ExpiresAt *int64 `json:"expiresAt"`
+}
+
+// ApiToken is what the admins see of a token, the token itself is only shown once
+type ApiToken struct {
+	Id                    int `json:"id"`
+	CreateApiTokenPayload `json:",inline"`
+	// the admin who made it, the token can't do more than they can
+	AdminId int `json:"adminId"`
+	// unix seconds
+	LastUsedAt *int64 `json:"lastUsedAt"`
+}
+
+func (token ApiToken) HasScope(scope ApiTokenScope) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type insertApiTokenPayload struct {
+	CreateApiTokenPayload `json:",inline"`
+	AdminId               int    `json:"adminId"`
+	TokenHash             string `json:"tokenHash"`
+}
+
+const apiTokenColumns = "id,name,scopes,expiresAt,adminId,lastUsedAt"
+
+type ApiTokenTable struct {
+	client *supabase.Client
+}
+
+func (db ApiTokenTable) GetAll() ([]ApiToken, error) {
+	query := db.from().Select(apiTokenColumns, "exact", false)
+	query = orderById(query)
+	data, _, err := query.Execute()
+	tokens := make([]ApiToken, 0)
+	json.Unmarshal(data, &tokens)
+	return tokens, err
+}
+
+func (db ApiTokenTable) GetByHash(tokenHash string) (ApiToken, error) {
+	query := db.from().Select(apiTokenColumns, "exact", false)
+	query = query.Filter("tokenHash", "eq", tokenHash).Single()
+	data, _, err := query.Execute()
+	var token ApiToken
+	json.Unmarshal(data, &token)
+	return token, err
+}
+
+func (db ApiTokenTable) Create(payload CreateApiTokenPayload, adminId int, tokenHash string) (ApiToken, error) {
+	query := insertSingle(db.from(), insertApiTokenPayload{
+		CreateApiTokenPayload: payload,
+		AdminId:               adminId,
+		TokenHash:             tokenHash,
+	})
+	data, _, err := query.Execute()
+	var result ApiToken
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db ApiTokenTable) SetLastUsedAt(id int, lastUsedAt int64) error {
+	query := db.from().Update(map[string]int64{"lastUsedAt": lastUsedAt}, "minimal", "")
+	query = query.Filter("id", "eq", strconv.Itoa(id))
+	_, _, err := query.Execute()
+	return err
+}
+
+func (db ApiTokenTable) Delete(id int) error {
+	query := deleteSingle(db.from())
+	query = filterById(query, id)
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table ApiTokenTable) from() *postgrest.QueryBuilder {
+	return table.client.From("api_tokens")
+}
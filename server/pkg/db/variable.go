@@ -0,0 +1,47 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/supabase-community/supabase-go"
+)
+
+// Variable is a campaign wide value that action templates can refer to as {{campaign.name}}
+type Variable struct {
+	Name  string `json:"name" binding:"required"`
+	Value string `json:"value"`
+}
+
+type VariableTable struct {
+	client *supabase.Client
+}
+
+func (db VariableTable) GetAll() ([]Variable, error) {
+	query := selectAll(db.from())
+	query = query.Order("name", &postgrest.OrderOpts{Ascending: true})
+	data, _, err := query.Execute()
+	variables := make([]Variable, 0)
+	json.Unmarshal(data, &variables)
+	return variables, err
+}
+
+// Set creates or replaces the variable with the same name
+func (db VariableTable) Set(variable Variable) (Variable, error) {
+	query := db.from().Insert(variable, true, "name", "", "exact").Single()
+	data, _, err := query.Execute()
+	var result Variable
+	json.Unmarshal(data, &result)
+	return result, err
+}
+
+func (db VariableTable) Delete(name string) error {
+	query := deleteSingle(db.from())
+	query = query.Filter("name", "eq", name).Single()
+	_, _, err := query.Execute()
+	return err
+}
+
+func (table VariableTable) from() *postgrest.QueryBuilder {
+	return table.client.From("variables")
+}
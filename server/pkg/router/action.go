@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
 )
 
 func (r *Router) CreateAction(c *gin.Context, input *db.CreateActionPayload) error {
@@ -21,8 +22,13 @@ func (r *Router) UpdateAction(c *gin.Context, input *db.Action) error {
 	}
 	r.stream.SendAdminActionMessage(action)
 	if playerId != 0 {
-		r.stream.SendPlayerActionMessage(playerId, action)
+		rendered, err := r.TemplateService.RenderAction(action)
+		if err != nil {
+			return err
+		}
+		r.stream.SendPlayerActionMessage(playerId, action, rendered)
 	}
+	r.SpectatorService.Sync(action.CharacterId)
 	return nil
 }
 
@@ -38,14 +44,45 @@ func (r Router) RevealAction(c *gin.Context) error {
 		return err
 	}
 
-	playerId, action, err := r.ActionService.Reveal(input.ActionId)
+	revealed, err := r.revealAction(input.ActionId)
 	if err != nil {
 		return err
 	}
-	r.stream.SendPlayerActionMessage(playerId, action)
+	if revealed {
+		r.HistoryService.Record(historySession(c), services.HistoryEntry{
+			Type:     services.HistoryRevealAction,
+			ActionId: input.ActionId,
+		})
+	}
 	return nil
 }
 
+// revealAction reveals and streams an action, reporting whether anything changed
+func (r Router) revealAction(actionId int) (bool, error) {
+	return r.sendRevealedAction(r.ActionService.Reveal(actionId))
+}
+
+// restoreAction is revealAction for undo and redo, it doesn't set off the reveal rules again
+func (r Router) restoreAction(actionId int) (bool, error) {
+	return r.sendRevealedAction(r.ActionService.Restore(actionId))
+}
+
+func (r Router) sendRevealedAction(playerId int, action db.Action, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	if playerId == 0 {
+		return false, nil
+	}
+	rendered, err := r.TemplateService.RenderAction(action)
+	if err != nil {
+		return true, err
+	}
+	r.stream.SendPlayerActionMessage(playerId, action, rendered)
+	r.SpectatorService.Sync(action.CharacterId)
+	return true, nil
+}
+
 type UnassignActionInput struct {
 	ActionId int `uri:"actionId" binding:"required,gt=0"`
 }
@@ -56,13 +93,37 @@ func (r Router) HideAction(c *gin.Context) error {
 	if err != nil {
 		return err
 	}
-	playerId, action, err := r.ActionService.Hide(input.ActionId)
+	hidden, err := r.hideAction(input.ActionId)
 	if err != nil {
 		return err
 	}
-	r.stream.SendHideActionMessage(playerId, action)
+	if hidden {
+		r.HistoryService.Record(historySession(c), services.HistoryEntry{
+			Type:     services.HistoryHideAction,
+			ActionId: input.ActionId,
+		})
+	}
 	return nil
+}
 
+// hideAction hides and streams an action, reporting whether anything changed
+func (r Router) hideAction(actionId int) (bool, error) {
+	playerId, action, err := r.ActionService.Hide(actionId)
+	if err != nil {
+		return false, err
+	}
+	if action.Id == 0 {
+		return false, nil
+	}
+	if playerId == 0 {
+		// nobody was looking at it, only the admin needs to know
+		r.stream.SendAdminActionMessage(action)
+		r.SpectatorService.Sync(action.CharacterId)
+		return true, nil
+	}
+	r.stream.SendHideActionMessage(playerId, action)
+	r.SpectatorService.Sync(action.CharacterId)
+	return true, nil
 }
 
 type DeleteInput struct {
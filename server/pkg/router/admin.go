@@ -0,0 +1,69 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+func (r Router) GetAdmins(c *gin.Context) ([]db.Admin, error) {
+	return r.AdminService.GetAll()
+}
+
+type CreateAdminInput struct {
+	db.CreateAdminPayload `json:",inline"`
+	// the owner passes it on, the new admin can change it after logging in.
+	// It can be left out when the admin has an email to log in with OIDC.
+	Password string `json:"password"`
+}
+
+func (r Router) CreateAdmin(c *gin.Context, input *CreateAdminInput) (db.Admin, error) {
+	admin, err := r.AdminService.Create(input.CreateAdminPayload, input.Password)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	r.stream.SendAdminAccountMessage(admin)
+	return admin, nil
+}
+
+type AdminInput struct {
+	AdminId int `uri:"adminId" binding:"required,gt=0"`
+}
+
+type AdminRoleInput struct {
+	Role db.AdminRole `json:"role" binding:"required,oneof=owner co-gm viewer"`
+}
+
+func (r Router) SetAdminRole(c *gin.Context, input *AdminRoleInput) (db.Admin, error) {
+	var adminInput AdminInput
+	err := c.BindUri(&adminInput)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	admin, err := r.AdminService.SetRole(adminInput.AdminId, input.Role)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	r.stream.SendAdminAccountMessage(admin)
+	return admin, nil
+}
+
+func (r Router) DeleteAdmin(c *gin.Context) error {
+	var input AdminInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	self := c.MustGet("admin").(db.Admin)
+	if self.Id == input.AdminId {
+		return fmt.Errorf("can't delete yourself")
+	}
+	err = r.AdminService.Delete(input.AdminId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendDeleteAdminMessage(input.AdminId)
+	return r.revokePlayerSessions(stream.AdminStreamId(input.AdminId))
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type AdminLoginInput struct {
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (r Router) AdminLogin(c *gin.Context, input *AdminLoginInput) (LoginResponse, error) {
+	ip := c.ClientIP()
+	err := r.reserveLogin(ip, input.Name)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	admin, err := r.AdminService.Login(input.Name, input.Password)
+	if err != nil {
+		r.loginFailed(ip, input.Name)
+		return LoginResponse{}, err
+	}
+	r.LoginLimiter.Succeed(ip, input.Name)
+	slog.Info("admin logged in", "adminId", admin.Id, "name", admin.Name, "ip", ip)
+	return r.startAdminSession(c, admin)
+}
+
+type AdminSetupInput struct {
+	// ADMIN_KEY
+	Key      string `json:"key" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// AdminSetup creates the first owner account, or gives an owner that only had a key a password.
+// After that it's just a way to get locked out.
+func (r Router) AdminSetup(c *gin.Context, input *AdminSetupInput) (LoginResponse, error) {
+	ip := c.ClientIP()
+	// guesses at the setup key count against one shared account
+	const account = "setup"
+	err := r.reserveLogin(ip, account)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	admin, err := r.AdminService.Setup(input.Key, input.Name, input.Password)
+	if err != nil {
+		r.loginFailed(ip, account)
+		return LoginResponse{}, err
+	}
+	r.LoginLimiter.Succeed(ip, account)
+	slog.Info("owner account set up", "adminId", admin.Id, "name", admin.Name, "ip", ip)
+	return r.startAdminSession(c, admin)
+}
+
+type AdminPasswordInput struct {
+	Current  string `json:"current" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// SetAdminPassword changes the password of the admin who's logged in
+func (r Router) SetAdminPassword(c *gin.Context, input *AdminPasswordInput) error {
+	admin := c.MustGet("admin").(db.Admin)
+	ip := c.ClientIP()
+	err := r.reserveLogin(ip, admin.Name)
+	if err != nil {
+		return err
+	}
+	_, err = r.AdminService.Login(admin.Name, input.Current)
+	if err != nil {
+		r.loginFailed(ip, admin.Name)
+		return err
+	}
+	r.LoginLimiter.Succeed(ip, admin.Name)
+	return r.AdminService.SetPassword(admin.Id, input.Password)
+}
+
+func (r Router) startAdminSession(c *gin.Context, admin db.Admin) (LoginResponse, error) {
+	player := db.Player{
+		Id:   stream.AdminStreamId(admin.Id),
+		Name: admin.Name,
+	}
+	err := r.setPlayerCookie(c, player)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	return LoginResponse{
+		Id:      player.Id,
+		Name:    player.Name,
+		IsAdmin: true,
+		Role:    admin.Role,
+	}, nil
+}
+
+// reserveLogin counts the attempt before the password is checked, the attempt only stops counting once it succeeds
+func (r Router) reserveLogin(ip string, account string) error {
+	until, ok := r.LoginLimiter.Attempt(ip, account)
+	if ok {
+		return nil
+	}
+	slog.Warn("admin login attempt while locked out", "ip", ip, "account", account, "until", until)
+	return fmt.Errorf("too many failed logins, try again in %s", time.Until(until).Round(time.Minute))
+}
+
+func (r Router) loginFailed(ip string, account string) {
+	slog.Warn("failed admin login", "ip", ip, "account", account)
+}
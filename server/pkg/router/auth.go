@@ -1,8 +1,11 @@
 package router
 
 import (
-	"encoding/json"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/justintoman/npc-surprise/pkg/db"
@@ -11,53 +14,39 @@ import (
 
 type LoginInput struct {
 	Name string `json:"name" binding:"required"`
+	// new players need the join code for the session
+	Code string `json:"code"`
 }
 
 func (r Router) Login(c *gin.Context, input *LoginInput) (LoginResponse, error) {
 	player, err := r.parsePlayerFromCookie(c)
 	if err != nil {
-		if input.Name == r.AdminKey {
-			// admin logging in
-			err = setPlayerCookie(c, db.Player{
-				Id:   0,
-				Name: "Admin",
-			})
-			if err != nil {
-				return LoginResponse{}, err
-			}
-			return LoginResponse{
-				Id:      0,
-				Name:    "Admin",
-				IsAdmin: true,
-			}, nil
+		// just a normie player logging in, they wait in the lobby until the GM lets them in
+		if !r.LobbyService.Check(input.Code) {
+			return LoginResponse{}, fmt.Errorf("that join code doesn't work, ask the GM for the current one")
 		}
-
-		// just a normie player logging in
 		player, err := r.PlayerService.Create(input.Name)
 		if err != nil {
 			return LoginResponse{}, err
 		}
 
-		err = setPlayerCookie(c, player)
+		err = r.setPlayerCookie(c, player)
 		if err != nil {
 			return LoginResponse{}, err
 		}
+		r.stream.SendPlayerMessage(player)
 
 		return LoginResponse{
 			Id:      player.Id,
 			Name:    player.Name,
 			IsAdmin: false,
+			Status:  player.Status,
 		}, nil
 	}
 
-	if player.Id == 0 {
-		// they're somehow the admin and logged in but logging in again?
-		// well it's not illegal...
-		return LoginResponse{
-			Id:      0,
-			Name:    "Admin",
-			IsAdmin: true,
-		}, nil
+	if stream.IsAdmin(player.Id) {
+		// admins log in at /admin/login, this just keeps them who they are
+		return r.adminStatus(player)
 	}
 
 	// they already have a player,
@@ -68,12 +57,7 @@ func (r Router) Login(c *gin.Context, input *LoginInput) (LoginResponse, error)
 	})
 
 	if err != nil {
-		clearPlayerCookie(c)
-		return LoginResponse{}, err
-	}
-
-	err = setPlayerCookie(c, player)
-	if err != nil {
+		r.clearPlayerCookie(c)
 		return LoginResponse{}, err
 	}
 
@@ -81,13 +65,17 @@ func (r Router) Login(c *gin.Context, input *LoginInput) (LoginResponse, error)
 		Id:      player.Id,
 		Name:    player.Name,
 		IsAdmin: false,
+		Status:  player.Status,
 	}, nil
 }
 
 type LoginResponse struct {
-	Id      int    `json:"id,omitempty"`
-	Name    string `json:"name,omitempty"`
-	IsAdmin bool   `json:"isAdmin"`
+	Id      int          `json:"id,omitempty"`
+	Name    string       `json:"name,omitempty"`
+	IsAdmin bool         `json:"isAdmin"`
+	Role    db.AdminRole `json:"role,omitempty"`
+	// players can only play once they're approved
+	Status db.PlayerStatus `json:"status,omitempty"`
 }
 
 type StatusResponse LoginResponse
@@ -100,29 +88,190 @@ func (r Router) Status(c *gin.Context) (StatusResponse, error) {
 		}, nil
 	}
 
+	if stream.IsAdmin(player.Id) {
+		response, err := r.adminStatus(player)
+		return StatusResponse(response), err
+	}
+
 	response := StatusResponse{
 		Id:      player.Id,
 		Name:    player.Name,
-		IsAdmin: player.Id == stream.AdminPlayerId,
+		IsAdmin: false,
+		Status:  player.Status,
 	}
 	return response, nil
 }
 
-func (r Router) AdminMiddleware(c *gin.Context) {
-	player, err := r.parsePlayerFromCookie(c)
+func (r Router) adminStatus(player db.Player) (LoginResponse, error) {
+	admin, err := r.AdminService.Get(stream.AdminId(player.Id))
 	if err != nil {
-		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. You are not Justin.", Status: 401})
+		return LoginResponse{}, err
+	}
+	return LoginResponse{
+		Id:      player.Id,
+		Name:    admin.Name,
+		IsAdmin: true,
+		Role:    admin.Role,
+	}, nil
+}
+
+// AdminMiddleware lets in admins whose role allows the request.
+// Viewers can only read, co-GMs can change things but not delete them, and owners can do anything.
+// Every change is attributed to the admin who made it.
+func (r Router) AdminMiddleware(c *gin.Context) {
+	admin, player, ok := r.authorizeAdmin(c, requiredRole(c.Request.Method))
+	if !ok {
 		return
 	}
 
-	if player.Id != stream.AdminPlayerId {
-		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. You are not Justin.", Status: 401})
+	c.Set("admin", admin)
+	c.Set("player", player)
+	c.Next()
+
+	if c.Request.Method == http.MethodGet || c.Writer.Status() >= 400 {
+		return
+	}
+	activity := stream.AdminActivity{
+		AdminId: admin.Id,
+		Name:    admin.Name,
+		Method:  c.Request.Method,
+		Path:    c.Request.URL.Path,
+		At:      time.Now().UnixMilli(),
+	}
+	if token, ok := c.Get("apiToken"); ok {
+		activity.Token = token.(db.ApiToken).Name
+	}
+	slog.Info("admin changed something", "adminId", admin.Id, "name", admin.Name, "token", activity.Token, "method", c.Request.Method, "path", c.Request.URL.Path)
+	r.stream.SendAdminActivityMessage(activity)
+}
+
+// SessionOnlyMiddleware goes after AdminMiddleware on routes api tokens can't use,
+// like managing admins and the tokens themselves
+func (r Router) SessionOnlyMiddleware(c *gin.Context) {
+	if _, ok := c.Get("apiToken"); ok {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: "API tokens can't do that, log in instead.", Status: 403})
+		return
+	}
+	c.Next()
+}
+
+// AccountMiddleware lets in any admin, whatever the method, for changing their own account
+func (r Router) AccountMiddleware(c *gin.Context) {
+	if _, ok := bearerToken(c); ok {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: "API tokens can't do that, log in instead.", Status: 403})
 		return
 	}
+	admin, player, ok := r.authorizeAdmin(c, db.AdminRoleViewer)
+	if !ok {
+		return
+	}
+	c.Set("admin", admin)
+	c.Set("player", player)
+	c.Next()
+}
 
+// OwnerMiddleware goes after AdminMiddleware on routes only owners can use, whatever the method
+func (r Router) OwnerMiddleware(c *gin.Context) {
+	admin := c.MustGet("admin").(db.Admin)
+	if !admin.Role.Can(db.AdminRoleOwner) {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: "Only an owner can do that.", Status: 403})
+		return
+	}
 	c.Next()
 }
 
+func requiredRole(method string) db.AdminRole {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return db.AdminRoleViewer
+	case http.MethodDelete:
+		return db.AdminRoleOwner
+	}
+	return db.AdminRoleCoGm
+}
+
+// requiredScope is what an api token needs for a request
+func requiredScope(c *gin.Context) db.ApiTokenScope {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		return db.ApiTokenScopeRead
+	}
+	if c.FullPath() == "/batch" {
+		return db.ApiTokenScopeReveal
+	}
+	for _, segment := range strings.Split(c.FullPath(), "/") {
+		if segment == "reveal" || segment == "hide" {
+			return db.ApiTokenScopeReveal
+		}
+	}
+	return db.ApiTokenScopeEdit
+}
+
+func bearerToken(c *gin.Context) (string, bool) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return strings.TrimSpace(token), ok
+}
+
+// authorizeAdmin accepts a session cookie or an api token
+func (r Router) authorizeAdmin(c *gin.Context, required db.AdminRole) (db.Admin, db.Player, bool) {
+	if token, ok := bearerToken(c); ok {
+		return r.authorizeApiToken(c, token, required)
+	}
+
+	player, err := r.parsePlayerFromCookie(c)
+	if err != nil || !stream.IsAdmin(player.Id) {
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. You are not Justin.", Status: 401})
+		return db.Admin{}, db.Player{}, false
+	}
+
+	admin, err := r.AdminService.Get(stream.AdminId(player.Id))
+	if err != nil {
+		r.clearPlayerCookie(c)
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. You are not Justin.", Status: 401})
+		return db.Admin{}, db.Player{}, false
+	}
+
+	if !admin.Role.Can(required) {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: fmt.Sprintf("A %s can't do that.", admin.Role), Status: 403})
+		return db.Admin{}, db.Player{}, false
+	}
+	return admin, player, true
+}
+
+// authorizeApiToken lets a token do what it has the scope for,
+// as long as the admin who made it could do it too
+func (r Router) authorizeApiToken(c *gin.Context, bearer string, required db.AdminRole) (db.Admin, db.Player, bool) {
+	token, err := r.ApiTokenService.Authenticate(bearer)
+	if err != nil {
+		slog.Warn("rejected api token", "error", err, "ip", c.ClientIP())
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. " + err.Error(), Status: 401})
+		return db.Admin{}, db.Player{}, false
+	}
+
+	scope := requiredScope(c)
+	if !token.HasScope(scope) {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: fmt.Sprintf("This token doesn't have the %s scope.", scope), Status: 403})
+		return db.Admin{}, db.Player{}, false
+	}
+
+	admin, err := r.AdminService.Get(token.AdminId)
+	if err != nil {
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Unauthorized. The admin who made this token is gone.", Status: 401})
+		return db.Admin{}, db.Player{}, false
+	}
+	if !admin.Role.Can(required) {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: fmt.Sprintf("A %s can't do that.", admin.Role), Status: 403})
+		return db.Admin{}, db.Player{}, false
+	}
+
+	c.Set("apiToken", token)
+	return admin, db.Player{
+		Id:   stream.AdminStreamId(admin.Id),
+		Name: admin.Name,
+	}, true
+}
+
+// PlayerMiddleware lets in admins and approved players, players still in the lobby have to wait
 func (r *Router) PlayerMiddleware(c *gin.Context) {
 	player, err := r.parsePlayerFromCookie(c)
 	if err != nil {
@@ -130,56 +279,87 @@ func (r *Router) PlayerMiddleware(c *gin.Context) {
 		return
 	}
 
+	if !stream.IsAdmin(player.Id) && !player.IsApproved() {
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: "The GM hasn't let you in yet.", Status: 403})
+		return
+	}
+
 	c.Set("player", player)
 	c.Next()
 }
 
+// parsePlayerFromCookie gets the player from the signed session cookie.
+// The cookie only holds the id, everything else comes from the db.
 func (r *Router) parsePlayerFromCookie(c *gin.Context) (db.Player, error) {
 	cookie, err := c.Cookie("player")
 	if err != nil {
 		// player cookie is boned, unset it
 		slog.Info("player cookie is boned, unset it", "error", err)
-		clearPlayerCookie(c)
+		r.clearPlayerCookie(c)
 		return db.Player{}, err
 	}
 
-	var player db.Player
-	err = json.Unmarshal([]byte(cookie), &player)
+	session, refresh, err := r.SessionService.Parse(cookie)
 	if err != nil {
 		// player cookie is boned, unset it
-		slog.Info("player cookie failed to be unmarshalled, unset it", "error", err)
-		clearPlayerCookie(c)
+		slog.Info("player cookie failed to be verified, unset it", "error", err)
+		r.clearPlayerCookie(c)
 		return db.Player{}, err
 	}
 
-	if player.Id == stream.AdminPlayerId {
-		slog.Info("player cookie is admin", "playerId", player.Id, "playerName", player.Name)
-		return player, nil
+	var player db.Player
+	if stream.IsAdmin(session.Id) {
+		admin, err := r.AdminService.Get(stream.AdminId(session.Id))
+		if err != nil {
+			slog.Info("cookie seems good but there's no admin in the db for it", "error", err, "id", session.Id)
+			r.clearPlayerCookie(c)
+			return db.Player{}, err
+		}
+		slog.Info("player cookie is admin", "adminId", admin.Id, "playerName", admin.Name)
+		player = db.Player{
+			Id:   session.Id,
+			Name: admin.Name,
+		}
+	} else {
+		player, err = r.PlayerService.Get(session.Id)
+		if err != nil {
+			// player cookie is boned, unset it
+			slog.Info("cookie seems good but there's no player in the db for it", "error", err, "id", session.Id)
+			r.clearPlayerCookie(c)
+			return db.Player{}, err
+		}
+		slog.Info("player cookie is valid", "playerId", player.Id, "playerName", player.Name)
 	}
 
-	_, err = r.PlayerService.Get(player.Id)
-	if err != nil {
-		// player cookie is boned, unset it
-		slog.Info("cookie seems good but there's no player in the db for it", "error", err, "player", player)
-		clearPlayerCookie(c)
-		return db.Player{}, err
+	if refresh {
+		token, _, err := r.SessionService.Refresh(session)
+		if err != nil {
+			slog.Error("failed to refresh session", "error", err, "id", player.Id)
+		} else {
+			r.writeSessionCookie(c, token)
+		}
 	}
 
-	slog.Info("player cookie is valid", "playerId", player.Id, "playerName", player.Name)
-
+	c.Set("sessionId", session.SessionId)
 	return player, nil
 }
 
-func setPlayerCookie(c *gin.Context, player db.Player) error {
-	cookie, err := json.Marshal(player)
+// setPlayerCookie starts a new session for the player
+func (r Router) setPlayerCookie(c *gin.Context, player db.Player) error {
+	token, session, err := r.SessionService.Issue(player.Id)
 	if err != nil {
-		clearPlayerCookie(c)
+		r.clearPlayerCookie(c)
 		return err
 	}
-	c.SetCookie("player", string(cookie), 0, "/", "", false, true)
+	c.Set("sessionId", session.SessionId)
+	r.writeSessionCookie(c, token)
 	return nil
 }
 
-func clearPlayerCookie(c *gin.Context) {
-	c.SetCookie("player", "", -1, "/", "", false, true)
+func (r Router) writeSessionCookie(c *gin.Context, token string) {
+	r.setCookie(c, "player", token, int(r.SessionService.TTL().Seconds()))
+}
+
+func (r Router) clearPlayerCookie(c *gin.Context) {
+	r.setCookie(c, "player", "", -1)
 }
@@ -0,0 +1,29 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+type BatchInput struct {
+	Operations []services.BatchOperation `json:"operations" binding:"required,min=1"`
+}
+
+func (r Router) Batch(c *gin.Context, input *BatchInput) error {
+	admin, players, err := r.BatchService.Apply(input.Operations)
+	// whatever was applied before a failure still has to reach the screens
+	if len(admin.Actions) > 0 || len(admin.Characters) > 0 {
+		r.stream.SendBatchMessages(admin, players)
+	}
+	characterIds := make(map[int]bool)
+	for _, action := range admin.Actions {
+		characterIds[action.CharacterId] = true
+	}
+	for _, character := range admin.Characters {
+		characterIds[character.Character.Id] = true
+	}
+	for characterId := range characterIds {
+		r.SpectatorService.Sync(characterId)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
 )
 
 type CreateCharacterInput struct {
@@ -34,6 +35,7 @@ func (r Router) UpdateCharacter(c *gin.Context, input *db.Character) error {
 	}
 	r.stream.SendAdminCharacterMessage(character)
 	r.stream.SendPlayerCharacterMessage(playerCharacter)
+	r.SpectatorService.Sync(character.Id)
 	return nil
 }
 
@@ -49,13 +51,27 @@ func (r Router) AssignCharacter(c *gin.Context) error {
 		return err
 	}
 
-	prevPlayerId, character, err := r.CharacterService.Assign(input.CharacterId, input.PlayerId)
+	prevPlayerId, err := r.assignCharacter(input.CharacterId, input.PlayerId)
 	if err != nil {
 		return err
 	}
+	r.HistoryService.Record(historySession(c), services.HistoryEntry{
+		Type:         services.HistoryAssign,
+		CharacterId:  input.CharacterId,
+		PlayerId:     input.PlayerId,
+		PrevPlayerId: prevPlayerId,
+	})
+	return nil
+}
+
+func (r Router) assignCharacter(characterId int, playerId int) (*int, error) {
+	prevPlayerId, character, err := r.CharacterService.Assign(characterId, playerId)
+	if err != nil {
+		return nil, err
+	}
 	redacted, err := r.CharacterService.Redact(character)
 	if err != nil {
-		return err
+		return prevPlayerId, err
 	}
 	r.stream.SendPlayerCharacterMessage(redacted)
 	if prevPlayerId != nil {
@@ -63,7 +79,8 @@ func (r Router) AssignCharacter(c *gin.Context) error {
 	} else {
 		r.stream.SendAdminCharacterMessage(character)
 	}
-	return nil
+	r.SpectatorService.Sync(characterId)
+	return prevPlayerId, nil
 }
 
 type UnassignCharacterInput struct {
@@ -77,14 +94,39 @@ func (r Router) UnassignCharacter(c *gin.Context) error {
 		return err
 	}
 
-	playerId, character, err := r.CharacterService.Unassign(input.CharacterId)
+	playerId, hiddenActionIds, err := r.unassignCharacter(input.CharacterId)
 	if err != nil {
 		return err
 	}
-	r.stream.SendHideCharacterMessage(playerId, character)
+	r.HistoryService.Record(historySession(c), services.HistoryEntry{
+		Type:            services.HistoryUnassign,
+		CharacterId:     input.CharacterId,
+		PrevPlayerId:    &playerId,
+		HiddenActionIds: hiddenActionIds,
+	})
 	return nil
 }
 
+// unassignCharacter returns the player it was assigned to and the actions that got hidden
+func (r Router) unassignCharacter(characterId int) (int, []int, error) {
+	revealed, err := r.db.Action.GetAllRevealed(characterId)
+	if err != nil {
+		return 0, nil, err
+	}
+	playerId, character, err := r.CharacterService.Unassign(characterId)
+	if err != nil {
+		return 0, nil, err
+	}
+	r.stream.SendHideCharacterMessage(playerId, character)
+	r.SpectatorService.Sync(characterId)
+
+	hiddenActionIds := make([]int, len(revealed))
+	for i, action := range revealed {
+		hiddenActionIds[i] = action.Id
+	}
+	return playerId, hiddenActionIds, nil
+}
+
 type CharacterReveleadFieldsInput struct {
 	CharacterId int   `json:"characterId" validate:"required"`
 	Name        *bool `json:"name" validate:"required"`
@@ -93,10 +135,19 @@ type CharacterReveleadFieldsInput struct {
 	Age         *bool `json:"age" validate:"required"`
 	Description *bool `json:"description" validate:"required"`
 	Appearance  *bool `json:"appearance" validate:"required"`
+	// optional so older clients keep working, missing means unchanged
+	Portrait *bool `json:"portrait"`
 }
 
 func (r Router) UpdateRevealedFields(c *gin.Context, input *CharacterReveleadFieldsInput) error {
-	character, fields, err := r.CharacterService.UpdateRevealedFields(db.CharacterReveleadFields{
+	prevFields, err := r.db.Character.GetRevealedFields(input.CharacterId)
+	if err != nil {
+		return err
+	}
+	if input.Portrait == nil {
+		input.Portrait = &prevFields.Portrait
+	}
+	fields := db.CharacterReveleadFields{
 		CharacterId: input.CharacterId,
 		Name:        *input.Name,
 		Race:        *input.Race,
@@ -104,7 +155,31 @@ func (r Router) UpdateRevealedFields(c *gin.Context, input *CharacterReveleadFie
 		Age:         *input.Age,
 		Description: *input.Description,
 		Appearance:  *input.Appearance,
+		Portrait:    *input.Portrait,
+	}
+	err = r.updateRevealedFields(fields)
+	if err != nil {
+		return err
+	}
+	r.HistoryService.Record(historySession(c), services.HistoryEntry{
+		Type:        services.HistoryFields,
+		CharacterId: input.CharacterId,
+		Fields:      fields,
+		PrevFields:  prevFields,
 	})
+	return nil
+}
+
+func (r Router) updateRevealedFields(input db.CharacterReveleadFields) error {
+	return r.sendRevealedFields(r.CharacterService.UpdateRevealedFields(input))
+}
+
+// restoreRevealedFields is updateRevealedFields for undo and redo, it doesn't set off the reveal rules again
+func (r Router) restoreRevealedFields(input db.CharacterReveleadFields) error {
+	return r.sendRevealedFields(r.CharacterService.RestoreRevealedFields(input))
+}
+
+func (r Router) sendRevealedFields(character db.CharacterWithActions, fields db.CharacterReveleadFields, err error) error {
 	if err != nil {
 		return err
 	}
@@ -114,6 +189,7 @@ func (r Router) UpdateRevealedFields(c *gin.Context, input *CharacterReveleadFie
 	}
 	r.stream.SendAdminCharacterMessageWithFields(character, fields)
 	r.stream.SendPlayerCharacterMessage(redacted)
+	r.SpectatorService.Sync(character.Id)
 	return nil
 }
 
@@ -134,5 +210,6 @@ func (r Router) DeleteCharacter(c *gin.Context) error {
 	}
 
 	r.stream.SendDeleteCharacterMessage(input.Id)
+	r.stream.SendSpectatorDeleteCharacterMessage(input.Id)
 	return nil
 }
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setCookie sets an http only cookie with the attributes from the config.
+// maxAge works like it does for gin, 0 for a browser session and -1 to delete it.
+func (r Router) setCookie(c *gin.Context, name string, value string, maxAge int) {
+	sameSite := r.cookies.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, "/", r.cookies.Domain, r.secureCookies(c), true)
+}
+
+// secureCookies is true for https, including https that a proxy like fly.io terminated for us.
+// SameSite=None cookies are thrown away by browsers unless they're secure.
+func (r Router) secureCookies(c *gin.Context) bool {
+	if r.cookies.Secure || r.cookies.SameSite == http.SameSiteNoneMode || c.Request.TLS != nil {
+		return true
+	}
+	return r.cookies.BehindProxy && c.GetHeader("X-Forwarded-Proto") == "https"
+}
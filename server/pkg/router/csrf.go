@@ -0,0 +1,61 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const csrfCookie = "csrf"
+const csrfHeader = "X-CSRF-Token"
+
+// CSRFMiddleware makes every request that changes something prove it came from our own page.
+// The token lives in an http only cookie and is handed out in the X-CSRF-Token response header,
+// and changes have to send it back in the same header. Another site can make the browser send
+// the cookie, but it can't read the header to copy it.
+func (r Router) CSRFMiddleware(c *gin.Context) {
+	token, err := c.Cookie(csrfCookie)
+	if err != nil || token == "" {
+		random := make([]byte, 32)
+		_, err = rand.Read(random)
+		if err != nil {
+			c.AbortWithStatusJSON(500, ErrorResponse{Message: "Couldn't make a CSRF token.", Status: 500})
+			return
+		}
+		token = hex.EncodeToString(random)
+		r.setCookie(c, csrfCookie, token, 0)
+	}
+	c.Header(csrfHeader, token)
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	// api tokens don't come from a browser on their own, another site can't make it send one
+	if _, ok := bearerToken(c); ok {
+		c.Next()
+		return
+	}
+
+	sent := c.GetHeader(csrfHeader)
+	if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		slog.Warn("request failed the CSRF check", "method", c.Request.Method, "path", c.Request.URL.Path, "ip", c.ClientIP())
+		c.AbortWithStatusJSON(403, ErrorResponse{Message: "Missing or wrong CSRF token. Reload and try again.", Status: 403})
+		return
+	}
+	c.Next()
+}
+
+type CSRFResponse struct {
+	Token string `json:"token"`
+}
+
+// GetCSRFToken is for clients that can't read response headers, it's the same token
+func (r Router) GetCSRFToken(c *gin.Context) (CSRFResponse, error) {
+	return CSRFResponse{Token: c.Writer.Header().Get(csrfHeader)}, nil
+}
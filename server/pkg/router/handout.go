@@ -0,0 +1,140 @@
+package router
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+func (r Router) CreateHandout(c *gin.Context) error {
+	name := c.PostForm("name")
+	file, err := c.FormFile("file")
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = file.Filename
+	}
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, services.MaxHandoutBytes+1))
+	if err != nil {
+		return err
+	}
+
+	handout, err := r.HandoutService.Create(name, data)
+	if err != nil {
+		return err
+	}
+	r.stream.SendAdminHandoutMessage(handout)
+	return nil
+}
+
+type HandoutPlayerInput struct {
+	HandoutId int `uri:"handoutId" binding:"required,gt=0"`
+	PlayerId  int `uri:"playerId" binding:"required,gt=0"`
+}
+
+func (r Router) RevealHandout(c *gin.Context) error {
+	var input HandoutPlayerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	playerIds, handout, err := r.HandoutService.Reveal(input.HandoutId, input.PlayerId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendRevealHandoutMessage(playerIds, handout)
+	return nil
+}
+
+func (r Router) HideHandout(c *gin.Context) error {
+	var input HandoutPlayerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	playerIds, handout, err := r.HandoutService.Hide(input.HandoutId, input.PlayerId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendHideHandoutMessage(playerIds, handout)
+	return nil
+}
+
+type HandoutInput struct {
+	HandoutId int `uri:"handoutId" binding:"required,gt=0"`
+}
+
+func (r Router) RevealHandoutToAll(c *gin.Context) error {
+	var input HandoutInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	playerIds, handout, err := r.HandoutService.RevealToAll(input.HandoutId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendRevealHandoutMessage(playerIds, handout)
+	return nil
+}
+
+func (r Router) HideHandoutFromAll(c *gin.Context) error {
+	var input HandoutInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	playerIds, handout, err := r.HandoutService.HideFromAll(input.HandoutId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendHideHandoutMessage(playerIds, handout)
+	return nil
+}
+
+func (r Router) DeleteHandout(c *gin.Context) error {
+	var input HandoutInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	err = r.HandoutService.Delete(input.HandoutId)
+	if err != nil {
+		return err
+	}
+	r.stream.SendDeleteHandoutMessage(input.HandoutId)
+	return nil
+}
+
+type GetHandoutFileInput struct {
+	File string `uri:"file" binding:"required"`
+}
+
+func (r Router) GetHandoutFile(c *gin.Context) {
+	var input GetHandoutFileInput
+	err := c.ShouldBindUri(&input)
+	if err != nil {
+		c.AbortWithStatusJSON(400, ErrorResponse{Message: err.Error(), Status: 400})
+		return
+	}
+	player := c.MustGet("player").(db.Player)
+	path, handout, err := r.HandoutService.Path(input.File, player.Id, stream.IsAdmin(player.Id))
+	if err != nil {
+		slog.Info("handout not available to player", "error", err, "playerId", player.Id, "file", input.File)
+		c.AbortWithStatusJSON(404, ErrorResponse{Message: "Handout not found", Status: 404})
+		return
+	}
+	// access can be taken away, so don't let anything cache it
+	c.Header("Cache-Control", "private, no-store")
+	c.FileAttachment(path, handout.Name)
+}
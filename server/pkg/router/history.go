@@ -0,0 +1,105 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+func (r Router) Undo(c *gin.Context) (services.HistoryEntry, error) {
+	session := historySession(c)
+	entry, ok := r.HistoryService.PopUndo(session)
+	if !ok {
+		return services.HistoryEntry{}, fmt.Errorf("nothing to undo")
+	}
+	err := r.undo(entry)
+	if err != nil {
+		// it's dropped, one that can never work like undoing something that was deleted would block everything older
+		return services.HistoryEntry{}, fmt.Errorf("couldn't undo that, it's been dropped from the history: %w", err)
+	}
+	r.HistoryService.PushRedo(session, entry)
+	return entry, nil
+}
+
+func (r Router) Redo(c *gin.Context) (services.HistoryEntry, error) {
+	session := historySession(c)
+	entry, ok := r.HistoryService.PopRedo(session)
+	if !ok {
+		return services.HistoryEntry{}, fmt.Errorf("nothing to redo")
+	}
+	err := r.redo(entry)
+	if err != nil {
+		return services.HistoryEntry{}, fmt.Errorf("couldn't redo that, it's been dropped from the history: %w", err)
+	}
+	r.HistoryService.PushUndo(session, entry)
+	return entry, nil
+}
+
+// undo reverses an operation, sending the same messages as doing the opposite by hand.
+// It only covers the GM's own operation. Whatever reveal rules it set off, right away or delayed, stays the way the rules left it,
+// and neither undo nor redo sets the rules off again.
+func (r Router) undo(entry services.HistoryEntry) error {
+	switch entry.Type {
+	case services.HistoryRevealAction:
+		_, err := r.hideAction(entry.ActionId)
+		return err
+	case services.HistoryHideAction:
+		_, err := r.restoreAction(entry.ActionId)
+		return err
+	case services.HistoryAssign:
+		if entry.PrevPlayerId == nil {
+			_, _, err := r.unassignCharacter(entry.CharacterId)
+			return err
+		}
+		_, err := r.assignCharacter(entry.CharacterId, *entry.PrevPlayerId)
+		return err
+	case services.HistoryUnassign:
+		_, err := r.assignCharacter(entry.CharacterId, *entry.PrevPlayerId)
+		if err != nil {
+			return err
+		}
+		for _, actionId := range entry.HiddenActionIds {
+			_, err = r.restoreAction(actionId)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case services.HistoryFields:
+		return r.restoreRevealedFields(entry.PrevFields)
+	}
+	return fmt.Errorf("unknown history entry %q", entry.Type)
+}
+
+func (r Router) redo(entry services.HistoryEntry) error {
+	switch entry.Type {
+	case services.HistoryRevealAction:
+		// what the rules did the first time is still there
+		_, err := r.restoreAction(entry.ActionId)
+		return err
+	case services.HistoryHideAction:
+		_, err := r.hideAction(entry.ActionId)
+		return err
+	case services.HistoryAssign:
+		_, err := r.assignCharacter(entry.CharacterId, entry.PlayerId)
+		return err
+	case services.HistoryUnassign:
+		_, _, err := r.unassignCharacter(entry.CharacterId)
+		return err
+	case services.HistoryFields:
+		return r.restoreRevealedFields(entry.Fields)
+	}
+	return fmt.Errorf("unknown history entry %q", entry.Type)
+}
+
+// historySession is the GM whose undo stack an operation belongs to
+func historySession(c *gin.Context) string {
+	player, ok := c.Get("player")
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(player.(db.Player).Id)
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+const oidcCookie = "oidc"
+
+// the GM has this long to finish logging in at the provider
+const oidcCookieMaxAge = 10 * 60
+
+// OidcLogin sends the GM to the OIDC provider
+func (r Router) OidcLogin(c *gin.Context) {
+	url, login, err := r.OidcService.Start(c.Request.Context())
+	if err != nil {
+		c.AbortWithStatusJSON(503, ErrorResponse{Message: err.Error(), Status: 503})
+		return
+	}
+	value, err := json.Marshal(login)
+	if err != nil {
+		c.AbortWithStatusJSON(500, ErrorResponse{Message: err.Error(), Status: 500})
+		return
+	}
+	r.setOidcCookie(c, base64.RawURLEncoding.EncodeToString(value), oidcCookieMaxAge)
+	c.Redirect(http.StatusFound, url)
+}
+
+// OidcCallback is where the provider sends the GM back to, it starts a normal admin session
+func (r Router) OidcCallback(c *gin.Context) {
+	cookie, err := c.Cookie(oidcCookie)
+	r.setOidcCookie(c, "", -1)
+	if err != nil {
+		c.AbortWithStatusJSON(400, ErrorResponse{Message: "The login took too long, try again.", Status: 400})
+		return
+	}
+	// set by the provider when the GM cancels or something goes wrong there
+	if providerError := c.Query("error"); providerError != "" {
+		slog.Info("OIDC login failed at the provider", "error", providerError)
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "The login didn't work: " + providerError, Status: 401})
+		return
+	}
+
+	var login services.OidcLogin
+	value, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err == nil {
+		err = json.Unmarshal(value, &login)
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(400, ErrorResponse{Message: "The login didn't match, try again.", Status: 400})
+		return
+	}
+
+	admin, err := r.OidcService.Finish(c.Request.Context(), login, c.Query("state"), c.Query("code"))
+	if err != nil {
+		slog.Warn("failed OIDC login", "error", err, "ip", c.ClientIP())
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: err.Error(), Status: 401})
+		return
+	}
+	_, err = r.startAdminSession(c, admin)
+	if err != nil {
+		c.AbortWithStatusJSON(500, ErrorResponse{Message: err.Error(), Status: 500})
+		return
+	}
+	slog.Info("admin logged in with OIDC", "adminId", admin.Id, "name", admin.Name, "ip", c.ClientIP())
+	c.Redirect(http.StatusFound, "/")
+}
+
+// the provider sends the GM back with a top level navigation from another site,
+// so the cookie has to be lax even when the others are strict
+func (r Router) setOidcCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcCookie, value, maxAge, "/", r.cookies.Domain, r.secureCookies(c), true)
+}
@@ -12,12 +12,12 @@ type PlayerResponse struct {
 	IsOnline  bool `json:"isOnline"`
 }
 
-type DeletePlayerInput struct {
+type PlayerInput struct {
 	Id int `uri:"id" binding:"required" validate:"gt=0,required"`
 }
 
 func (r *Router) DeletePlayer(c *gin.Context) error {
-	var input DeletePlayerInput
+	var input PlayerInput
 	err := c.BindUri(&input)
 	if err != nil {
 		return err
@@ -30,5 +30,62 @@ func (r *Router) DeletePlayer(c *gin.Context) error {
 		return err
 	}
 	r.stream.SendDeletePlayerMessage(input.Id)
+	return r.revokePlayerSessions(input.Id)
+}
+
+func (r *Router) ApprovePlayer(c *gin.Context) (db.Player, error) {
+	return r.setPlayerStatus(c, db.PlayerStatusApproved)
+}
+
+// RejectPlayer keeps them out of the game, they stay in the list so the GM can change their mind
+func (r *Router) RejectPlayer(c *gin.Context) (db.Player, error) {
+	return r.setPlayerStatus(c, db.PlayerStatusRejected)
+}
+
+func (r *Router) setPlayerStatus(c *gin.Context, status db.PlayerStatus) (db.Player, error) {
+	var input PlayerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return db.Player{}, err
+	}
+
+	slog.Info("setting player status", "playerId", input.Id, "status", status)
+
+	player, err := r.PlayerService.SetStatus(input.Id, status)
+	if err != nil {
+		return db.Player{}, err
+	}
+	r.stream.SendPlayerMessage(player)
+	if !player.IsApproved() {
+		// their stream would keep going otherwise
+		r.stream.Disconnect(player.Id)
+	}
+	return player, nil
+}
+
+type JoinCodeResponse struct {
+	Code string `json:"code"`
+}
+
+func (r *Router) GetJoinCode(c *gin.Context) (JoinCodeResponse, error) {
+	return JoinCodeResponse{Code: r.LobbyService.Code()}, nil
+}
+
+// OpenLobby starts letting new players in with a fresh join code
+func (r *Router) OpenLobby(c *gin.Context) (JoinCodeResponse, error) {
+	code, err := r.LobbyService.Open()
+	if err != nil {
+		return JoinCodeResponse{}, err
+	}
+	r.stream.SendJoinCodeMessage(code)
+	return JoinCodeResponse{Code: code}, nil
+}
+
+func (r *Router) CloseLobby(c *gin.Context) error {
+	err := r.LobbyService.Close()
+	if err != nil {
+		return err
+	}
+	r.stream.SendJoinCodeMessage("")
 	return nil
 }
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+func (r Router) GetPolls(c *gin.Context) ([]db.Poll, error) {
+	return r.PollService.GetAll()
+}
+
+func (r Router) CreatePoll(c *gin.Context, input *db.CreatePollPayload) (db.Poll, error) {
+	poll, err := r.PollService.Create(*input)
+	if err != nil {
+		return db.Poll{}, err
+	}
+	r.stream.SendPollMessage(services.PollRecipients(poll), poll)
+	return poll, nil
+}
+
+type PollInput struct {
+	PollId int `uri:"pollId" binding:"required,gt=0"`
+}
+
+func (r Router) ClosePoll(c *gin.Context) (db.Poll, error) {
+	var input PollInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return db.Poll{}, err
+	}
+	poll, err := r.PollService.Close(input.PollId)
+	if err != nil {
+		return db.Poll{}, err
+	}
+	r.stream.SendPollResultMessage(services.PollRecipients(poll), poll)
+	return poll, nil
+}
+
+type VoteInput struct {
+	PollId int  `path:"pollId" json:"-" validate:"required,gt=0"`
+	Option *int `json:"option" validate:"required"`
+}
+
+func (r Router) PlayerVote(c *gin.Context, input *VoteInput) error {
+	player := c.MustGet("player").(db.Player)
+	poll, err := r.PollService.Vote(input.PollId, services.PlayerVoter(player.Id), false, *input.Option)
+	if err != nil {
+		return err
+	}
+	r.stream.SendPollTallyMessage(poll)
+	return nil
+}
+
+func (r Router) SpectatorVote(c *gin.Context, input *VoteInput) error {
+	spectator := c.MustGet("spectator").(Spectator)
+	if spectator.Key == "" {
+		return fmt.Errorf("join the audience again to vote")
+	}
+	poll, err := r.PollService.Vote(input.PollId, services.SpectatorVoter(spectator.Key), true, *input.Option)
+	if err != nil {
+		return err
+	}
+	r.stream.SendPollTallyMessage(poll)
+	return nil
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type UploadPortraitInput struct {
+	CharacterId int `uri:"characterId" binding:"required,gt=0"`
+}
+
+func (r Router) UploadPortrait(c *gin.Context) error {
+	var input UploadPortraitInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		return err
+	}
+	if file.Size > services.MaxPortraitBytes {
+		return fmt.Errorf("portrait is larger than %d bytes", services.MaxPortraitBytes)
+	}
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, services.MaxPortraitBytes+1))
+	if err != nil {
+		return err
+	}
+
+	character, err := r.PortraitService.Upload(input.CharacterId, data)
+	if err != nil {
+		return err
+	}
+	redacted, err := r.CharacterService.Redact(character)
+	if err != nil {
+		return err
+	}
+	r.stream.SendAdminCharacterMessage(character)
+	r.stream.SendPlayerCharacterMessage(redacted)
+	r.SpectatorService.Sync(character.Id)
+	return nil
+}
+
+type GetPortraitInput struct {
+	CharacterId int    `uri:"characterId" binding:"required,gt=0"`
+	File        string `uri:"file" binding:"required"`
+}
+
+func (r Router) GetPortrait(c *gin.Context) {
+	var input GetPortraitInput
+	err := c.ShouldBindUri(&input)
+	if err != nil {
+		c.AbortWithStatusJSON(400, ErrorResponse{Message: err.Error(), Status: 400})
+		return
+	}
+	player := c.MustGet("player").(db.Player)
+	path, err := r.PortraitService.Path(input.CharacterId, input.File, player.Id, stream.IsAdmin(player.Id), r.SpectatorService.TableViewEnabled())
+	if err != nil {
+		slog.Info("portrait not available to player", "error", err, "playerId", player.Id, "characterId", input.CharacterId)
+		c.AbortWithStatusJSON(404, ErrorResponse{Message: "Portrait not found", Status: 404})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(path)
+}
+
+// GetSpectatorPortrait serves the same files to the audience, as long as the portrait is public.
+// Spectators get the same urls as players, with /spectate in front.
+func (r Router) GetSpectatorPortrait(c *gin.Context) {
+	var input GetPortraitInput
+	err := c.ShouldBindUri(&input)
+	if err != nil {
+		c.AbortWithStatusJSON(400, ErrorResponse{Message: err.Error(), Status: 400})
+		return
+	}
+	path, err := r.PortraitService.PublicPath(input.CharacterId, input.File)
+	if err != nil {
+		slog.Info("portrait not available to spectators", "error", err, "characterId", input.CharacterId)
+		c.AbortWithStatusJSON(404, ErrorResponse{Message: "Portrait not found", Status: 404})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(path)
+}
@@ -3,6 +3,8 @@ package router
 import (
 	"context"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/justintoman/npc-surprise/pkg/db"
@@ -20,35 +22,135 @@ type ErrorResponse struct {
 type Router struct {
 	stream           stream.StreamingServer
 	db               db.Db
-	AdminKey         string
+	cookies          CookieConfig
+	AdminService     services.AdminService
+	SessionService   services.SessionService
+	LoginLimiter     *services.LoginLimiter
+	ApiTokenService  services.ApiTokenService
+	OidcService      *services.OidcService
 	ActionService    services.ActionService
 	CharacterService services.CharacterService
 	PlayerService    services.PlayerService
+	LobbyService     *services.LobbyService
+	SearchService    services.SearchService
+	PortraitService  services.PortraitService
+	HandoutService   services.HandoutService
+	TemplateService  services.TemplateService
+	RuleService      *services.RuleService
+	BatchService     services.BatchService
+	HistoryService   *services.HistoryService
+	SpectatorService services.SpectatorService
+	PollService      services.PollService
+	ScoreService     services.ScoreService
+	TimerService     *services.TimerService
 }
 
-func New(db db.Db, adminKey string) *gin.Engine {
-	streamService := stream.New(db)
+// Config is everything the router needs from the environment
+type Config struct {
+	AdminKey  string
+	UploadDir string
+	TableView bool
+	// the first secret signs sessions, the rest are still accepted while rotating
+	SessionSecrets []string
+	SessionTTL     time.Duration
+	Cookies        CookieConfig
+	Oidc           services.OidcConfig
+	Stream         stream.Config
+}
+
+type CookieConfig struct {
+	Domain   string
+	SameSite http.SameSite
+	// always mark cookies secure, otherwise they're only secure when the request is https
+	Secure bool
+	// fly.io terminates https and says who the client is in headers, trust those
+	BehindProxy bool
+}
+
+func New(db db.Db, config Config) *gin.Engine {
+	streamService := stream.New(db, config.Stream)
+	spectatorService := services.NewSpectatorService(db, streamService, config.TableView)
+	ruleService := services.NewRuleService(db, streamService, spectatorService)
+
+	actionService := services.NewActionService(db, ruleService)
+	characterService := services.NewCharacterService(db, streamService, ruleService)
 
 	router := Router{
 		stream:           streamService,
 		db:               db,
-		AdminKey:         adminKey,
-		ActionService:    services.NewActionService(db),
-		CharacterService: services.NewCharacterService(db, streamService),
+		cookies:          config.Cookies,
+		AdminService:     services.NewAdminService(db, config.AdminKey),
+		SessionService:   services.NewSessionService(db, config.SessionSecrets, config.SessionTTL),
+		LoginLimiter:     services.NewLoginLimiter(),
+		ApiTokenService:  services.NewApiTokenService(db),
+		OidcService:      services.NewOidcService(db, config.Oidc),
+		ActionService:    actionService,
+		CharacterService: characterService,
 		PlayerService:    services.NewPlayerService(db, streamService),
+		LobbyService:     services.NewLobbyService(db),
+		SearchService:    services.NewSearchService(db),
+		PortraitService:  services.NewPortraitService(db, config.UploadDir),
+		HandoutService:   services.NewHandoutService(db, config.UploadDir),
+		TemplateService:  services.NewTemplateService(db),
+		RuleService:      ruleService,
+		BatchService:     services.NewBatchService(db, actionService, characterService),
+		HistoryService:   services.NewHistoryService(),
+		SpectatorService: spectatorService,
+		PollService:      services.NewPollService(db),
+		ScoreService:     services.NewScoreService(db),
+		TimerService:     services.NewTimerService(db, streamService, actionService, spectatorService),
 	}
 
 	g := gin.Default()
+	if config.Cookies.BehindProxy {
+		g.TrustedPlatform = gin.PlatformFlyIO
+	}
+	// only the platform header says who the client is, X-Forwarded-For is whatever the client wants it to be
+	g.SetTrustedProxies(nil)
 
 	g.Use(spa.Middleware("/", "./dist"))
 
 	api := g.Group("/")
+	api.Use(router.CSRFMiddleware)
+	api.GET("/csrf", tonic.Handler(router.GetCSRFToken, 200))
 	api.POST("/login", tonic.Handler(router.Login, 200))
 	api.GET("/status", tonic.Handler(router.Status, 200))
+	api.POST("/spectate", tonic.Handler(router.Spectate, 200))
+	api.POST("/logout", tonic.Handler(router.Logout, 200))
+	api.POST("/admin/login", tonic.Handler(router.AdminLogin, 200))
+	api.POST("/admin/setup", tonic.Handler(router.AdminSetup, 200))
+	api.PUT("/admin/password", router.AccountMiddleware, tonic.Handler(router.SetAdminPassword, 200))
+	api.GET("/admin/oidc/login", router.OidcLogin)
+	api.GET("/admin/oidc/callback", router.OidcCallback)
 
 	adminRoutes := api.Group("/")
 	adminRoutes.Use(router.AdminMiddleware)
 	adminRoutes.DELETE("players/:id", tonic.Handler(router.DeletePlayer, 200))
+	adminRoutes.PUT("players/:id/approve", tonic.Handler(router.ApprovePlayer, 200))
+	adminRoutes.PUT("players/:id/reject", tonic.Handler(router.RejectPlayer, 200))
+	adminRoutes.POST("players/:id/revoke", tonic.Handler(router.RevokePlayerSessions, 200))
+	adminRoutes.POST("players/revoke", tonic.Handler(router.RevokeAllPlayerSessions, 200))
+	adminRoutes.GET("join-code", tonic.Handler(router.GetJoinCode, 200))
+	adminRoutes.POST("join-code", tonic.Handler(router.OpenLobby, 200))
+	adminRoutes.POST("join-code/close", tonic.Handler(router.CloseLobby, 200))
+	adminRoutes.GET("search", tonic.Handler(router.Search, 200))
+	adminRoutes.PUT("batch", tonic.Handler(router.Batch, 200))
+	adminRoutes.POST("undo", tonic.Handler(router.Undo, 200))
+	adminRoutes.POST("redo", tonic.Handler(router.Redo, 200))
+	adminRoutes.GET("stream/stats", tonic.Handler(router.GetStreamStats, 200))
+
+	accountRoutes := adminRoutes.Group("/admins")
+	accountRoutes.Use(router.SessionOnlyMiddleware)
+	accountRoutes.GET("", tonic.Handler(router.GetAdmins, 200))
+	accountRoutes.POST("", router.OwnerMiddleware, tonic.Handler(router.CreateAdmin, 200))
+	accountRoutes.PUT("/:adminId/role", router.OwnerMiddleware, tonic.Handler(router.SetAdminRole, 200))
+	accountRoutes.DELETE("/:adminId", tonic.Handler(router.DeleteAdmin, 200))
+
+	tokenRoutes := adminRoutes.Group("/tokens")
+	tokenRoutes.Use(router.SessionOnlyMiddleware)
+	tokenRoutes.GET("", tonic.Handler(router.GetApiTokens, 200))
+	tokenRoutes.POST("", tonic.Handler(router.CreateApiToken, 200))
+	tokenRoutes.POST("/:tokenId/revoke", tonic.Handler(router.RevokeApiToken, 200))
 
 	characterRoutes := adminRoutes.Group("/characters")
 	characterRoutes.POST("", tonic.Handler(router.CreateCharacter, 200))
@@ -56,6 +158,8 @@ func New(db db.Db, adminKey string) *gin.Engine {
 	characterRoutes.PUT("/:characterId/assign/:playerId", tonic.Handler(router.AssignCharacter, 200))
 	characterRoutes.PUT("/:characterId/unassign", tonic.Handler(router.UnassignCharacter, 200))
 	characterRoutes.PUT("/:characterId/reveal", tonic.Handler(router.UpdateRevealedFields, 200))
+	characterRoutes.PUT("/:characterId/public", tonic.Handler(router.UpdatePublicFields, 200))
+	characterRoutes.POST("/:characterId/portrait", tonic.Handler(router.UploadPortrait, 200))
 	characterRoutes.DELETE("/:characterId", tonic.Handler(router.DeleteCharacter, 200))
 
 	actionRoutes := characterRoutes.Group("/:characterId/actions")
@@ -65,10 +169,60 @@ func New(db db.Db, adminKey string) *gin.Engine {
 	actionRoutes.PUT(":actionId/hide", tonic.Handler(router.HideAction, 200))
 	actionRoutes.DELETE(":actionId", tonic.Handler(router.DeleteAction, 200))
 
+	ruleRoutes := adminRoutes.Group("/rules")
+	ruleRoutes.GET("", tonic.Handler(router.GetRules, 200))
+	ruleRoutes.POST("", tonic.Handler(router.CreateRule, 200))
+	ruleRoutes.DELETE("/:id", tonic.Handler(router.DeleteRule, 200))
+
+	variableRoutes := adminRoutes.Group("/variables")
+	variableRoutes.GET("", tonic.Handler(router.GetVariables, 200))
+	variableRoutes.PUT("", tonic.Handler(router.SetVariable, 200))
+	variableRoutes.DELETE("/:name", tonic.Handler(router.DeleteVariable, 200))
+
+	pollRoutes := adminRoutes.Group("/polls")
+	pollRoutes.GET("", tonic.Handler(router.GetPolls, 200))
+	pollRoutes.POST("", tonic.Handler(router.CreatePoll, 200))
+	pollRoutes.PUT("/:pollId/close", tonic.Handler(router.ClosePoll, 200))
+
+	scoreRoutes := adminRoutes.Group("/scores")
+	scoreRoutes.GET("", tonic.Handler(router.GetScores, 200))
+	scoreRoutes.POST("", tonic.Handler(router.AwardPoints, 200))
+	scoreRoutes.POST("/reset", tonic.Handler(router.ResetScores, 200))
+	scoreRoutes.POST("/leaderboard", tonic.Handler(router.SendLeaderboard, 200))
+
+	timerRoutes := adminRoutes.Group("/timers")
+	timerRoutes.GET("", tonic.Handler(router.GetTimers, 200))
+	timerRoutes.POST("", tonic.Handler(router.CreateTimer, 200))
+	timerRoutes.PUT("/:timerId/pause", tonic.Handler(router.PauseTimer, 200))
+	timerRoutes.PUT("/:timerId/resume", tonic.Handler(router.ResumeTimer, 200))
+	timerRoutes.DELETE("/:timerId", tonic.Handler(router.CancelTimer, 200))
+
+	handoutRoutes := adminRoutes.Group("/handouts")
+	handoutRoutes.POST("", tonic.Handler(router.CreateHandout, 200))
+	handoutRoutes.PUT("/:handoutId/reveal", tonic.Handler(router.RevealHandoutToAll, 200))
+	handoutRoutes.PUT("/:handoutId/hide", tonic.Handler(router.HideHandoutFromAll, 200))
+	handoutRoutes.PUT("/:handoutId/reveal/:playerId", tonic.Handler(router.RevealHandout, 200))
+	handoutRoutes.PUT("/:handoutId/hide/:playerId", tonic.Handler(router.HideHandout, 200))
+	handoutRoutes.DELETE("/:handoutId", tonic.Handler(router.DeleteHandout, 200))
+
 	authRoutes := api.Group("/")
+	authRoutes.GET("/portraits/:characterId/:file", router.PlayerMiddleware, router.GetPortrait)
+	authRoutes.GET("/spectate/portraits/:characterId/:file", router.SpectatorMiddleware, router.GetSpectatorPortrait)
+	authRoutes.GET("/handout-files/:file", router.PlayerMiddleware, router.GetHandoutFile)
+	authRoutes.GET("/table", router.PlayerMiddleware, tonic.Handler(router.GetTable, 200))
 
-	middleware, handler := streamService.NewUserStream(router.onPlayerConnected, router.onPlayerDisconnected)
+	middleware, handler := streamService.NewUserStream(router.onPlayerConnected, router.onPlayerDisconnected, router.sendInitMessages, router.onClientMessage)
 	authRoutes.GET("/stream", router.PlayerMiddleware, middleware, tonic.Handler(handler, 200))
+	authRoutes.GET("/spectate/stream", router.SpectatorMiddleware, middleware, tonic.Handler(handler, 200))
+	// same messages as the streams, but players can talk back
+	authRoutes.GET("/ws", router.PlayerMiddleware, middleware, webSocketHandler(handler))
+	authRoutes.GET("/spectate/ws", router.SpectatorMiddleware, middleware, webSocketHandler(handler))
+	// for networks that break both
+	pollHandler := streamService.NewPollHandler(router.onPlayerConnected, router.onPlayerDisconnected, router.sendInitMessages)
+	authRoutes.GET("/poll", router.PlayerMiddleware, tonic.Handler(pollHandler, 200))
+	authRoutes.GET("/spectate/poll", router.SpectatorMiddleware, tonic.Handler(pollHandler, 200))
+	authRoutes.POST("/votes/:pollId", router.PlayerMiddleware, tonic.Handler(router.PlayerVote, 200))
+	authRoutes.POST("/spectate/votes/:pollId", router.SpectatorMiddleware, tonic.Handler(router.SpectatorVote, 200))
 
 	ctx := context.Background()
 	go streamService.Listen(ctx)
@@ -76,8 +230,44 @@ func New(db db.Db, adminKey string) *gin.Engine {
 	return g
 }
 
-func (r *Router) onPlayerConnected(player db.Player) {
-	if player.Id == stream.AdminPlayerId {
+func (r *Router) onPlayerConnected(player db.Player, resumed bool) {
+	if !stream.IsSpectator(player.Id) && !stream.IsAdmin(player.Id) {
+		r.stream.SendPlayerConnectedMessage(player)
+	}
+	// a resumed client already got what it missed
+	if !resumed {
+		r.sendInitMessages(player)
+	}
+}
+
+// sendInitMessages sends a client everything it needs to show the game from scratch
+func (r *Router) sendInitMessages(player db.Player) {
+	if stream.IsSpectator(player.Id) {
+		characters, err := r.SpectatorService.GetAllPublic()
+		if err != nil {
+			slog.Error("error getting characters for spectator", "error", err)
+			return
+		}
+		r.stream.SendInitSpectatorMessage(player.Id, characters)
+		polls, err := r.PollService.GetAllOpenFor(true)
+		if err != nil {
+			slog.Error("error getting polls for spectator", "error", err)
+			return
+		}
+		r.stream.SendInitPollsMessage(player.Id, polls)
+		return
+	}
+	if stream.IsAdmin(player.Id) {
+		admin, err := r.AdminService.Get(stream.AdminId(player.Id))
+		if err != nil {
+			slog.Error("error getting admin", "error", err, "id", player.Id)
+			return
+		}
+		admins, err := r.AdminService.GetAll()
+		if err != nil {
+			slog.Error("error getting admins", "error", err)
+			return
+		}
 		characters, fields, err := r.CharacterService.GetAllWithActionsAndFields()
 		if err != nil {
 			slog.Error("error getting characters for player", "error", err)
@@ -88,18 +278,64 @@ func (r *Router) onPlayerConnected(player db.Player) {
 			slog.Error("error getting players", "error", err)
 			return
 		}
-		r.stream.SendInitAdminMessage(players, characters, fields)
+		handouts, err := r.HandoutService.GetAll()
+		if err != nil {
+			slog.Error("error getting handouts", "error", err)
+			return
+		}
+		publicFields, err := r.SpectatorService.GetAllPublicFields()
+		if err != nil {
+			slog.Error("error getting public fields", "error", err)
+			return
+		}
+		r.stream.SendInitAdminMessage(player.Id, admin.Role, admins, r.LobbyService.Code(), players, characters, fields, handouts, publicFields)
+		polls, err := r.PollService.GetAll()
+		if err != nil {
+			slog.Error("error getting polls", "error", err)
+			return
+		}
+		r.stream.SendInitPollsMessage(player.Id, polls)
+		scoreboard, err := r.ScoreService.GetScoreboard()
+		if err != nil {
+			slog.Error("error getting scores", "error", err)
+			return
+		}
+		r.stream.SendScoreboardMessage(scoreboard)
+		r.stream.SendInitTimersMessage(player.Id, r.TimerService.GetAll())
 	} else {
-		r.stream.SendPlayerConnectedMessage(player)
 		characters, err := r.CharacterService.GetAllAssignedWithActionsRedacted(player.Id)
 		if err != nil {
 			slog.Error("error getting characters for player", "error", err, "playerId", player.Id)
 			return
 		}
 		r.stream.SendInitPlayerMessage(player.Id, characters)
+		handouts, err := r.HandoutService.GetAllRevealedRedacted(player.Id)
+		if err != nil {
+			slog.Error("error getting handouts for player", "error", err, "playerId", player.Id)
+			return
+		}
+		r.stream.SendInitHandoutsMessage(player.Id, handouts)
+		polls, err := r.PollService.GetAllOpenFor(false)
+		if err != nil {
+			slog.Error("error getting polls for player", "error", err, "playerId", player.Id)
+			return
+		}
+		r.stream.SendInitPollsMessage(player.Id, polls)
+		r.stream.SendInitTimersMessage(player.Id, r.TimerService.GetAllForPlayer(player.Id))
+		if r.SpectatorService.TableViewEnabled() {
+			table, err := r.SpectatorService.GetTable()
+			if err != nil {
+				slog.Error("error getting table view for player", "error", err, "playerId", player.Id)
+				return
+			}
+			r.stream.SendTableMessage(player.Id, table)
+		}
 	}
 }
 
 func (r *Router) onPlayerDisconnected(player db.Player) {
+	if stream.IsSpectator(player.Id) {
+		return
+	}
 	r.stream.SendPlayerDisconnectedMessage(player.Id)
 }
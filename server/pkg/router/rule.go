@@ -0,0 +1,23 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+func (r Router) GetRules(c *gin.Context) ([]db.RevealRule, error) {
+	return r.RuleService.GetAll()
+}
+
+func (r Router) CreateRule(c *gin.Context, input *db.CreateRevealRulePayload) (db.RevealRule, error) {
+	return r.RuleService.Create(*input)
+}
+
+func (r Router) DeleteRule(c *gin.Context) error {
+	var input DeleteInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	return r.RuleService.Delete(input.Id)
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type AwardInput struct {
+	db.CreateScoreEntryPayload `json:",inline"`
+	// also push the new totals to every player
+	Leaderboard bool `json:"leaderboard"`
+}
+
+func (r Router) GetScores(c *gin.Context) (stream.ScoreboardData, error) {
+	return r.ScoreService.GetScoreboard()
+}
+
+func (r Router) AwardPoints(c *gin.Context, input *AwardInput) (db.ScoreEntry, error) {
+	entry, err := r.ScoreService.Award(input.CreateScoreEntryPayload)
+	if err != nil {
+		return db.ScoreEntry{}, err
+	}
+	err = r.sendScores(input.Leaderboard)
+	return entry, err
+}
+
+type ScoresInput struct {
+	Leaderboard bool `json:"leaderboard"`
+}
+
+func (r Router) ResetScores(c *gin.Context, input *ScoresInput) error {
+	err := r.ScoreService.Reset()
+	if err != nil {
+		return err
+	}
+	return r.sendScores(input.Leaderboard)
+}
+
+func (r Router) SendLeaderboard(c *gin.Context) error {
+	return r.sendScores(true)
+}
+
+func (r Router) sendScores(leaderboard bool) error {
+	scoreboard, err := r.ScoreService.GetScoreboard()
+	if err != nil {
+		return err
+	}
+	r.stream.SendScoreboardMessage(scoreboard)
+	if leaderboard {
+		r.stream.SendLeaderboardMessage(scoreboard.Totals)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+type SearchInput struct {
+	Query string `query:"q" validate:"required"`
+	Limit int    `query:"limit" default:"25" validate:"gte=0,lte=100"`
+}
+
+func (r Router) Search(c *gin.Context, input *SearchInput) ([]services.SearchResult, error) {
+	return r.SearchService.Search(input.Query, input.Limit)
+}
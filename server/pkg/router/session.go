@@ -0,0 +1,60 @@
+package router
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+// Logout ends the session of the device it's called from, and closes its stream
+func (r Router) Logout(c *gin.Context) error {
+	cookie, err := c.Cookie("player")
+	r.clearPlayerCookie(c)
+	if err != nil {
+		return nil
+	}
+	session, _, err := r.SessionService.Parse(cookie)
+	if err != nil {
+		// already as logged out as it gets
+		return nil
+	}
+	err = r.SessionService.Revoke(session.SessionId)
+	if err != nil {
+		return err
+	}
+	slog.Info("logged out", "id", session.Id)
+	r.stream.DisconnectSession(session.SessionId)
+	return nil
+}
+
+// RevokePlayerSessions logs a player out everywhere, right now
+func (r Router) RevokePlayerSessions(c *gin.Context) error {
+	var input PlayerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	return r.revokePlayerSessions(input.Id)
+}
+
+// RevokeAllPlayerSessions logs out every player, the admins stay logged in
+func (r Router) RevokeAllPlayerSessions(c *gin.Context) error {
+	err := r.SessionService.RevokeAllPlayers()
+	if err != nil {
+		return err
+	}
+	slog.Info("revoked every player's sessions")
+	r.stream.Disconnect(stream.AllPlayersId)
+	return nil
+}
+
+func (r Router) revokePlayerSessions(playerId int) error {
+	err := r.SessionService.RevokeAll(playerId)
+	if err != nil {
+		return err
+	}
+	slog.Info("revoked sessions", "id", playerId)
+	r.stream.Disconnect(playerId)
+	return nil
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type SpectateInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type Spectator struct {
+	// random, identifies the spectator for things like voting
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Spectate logs in an audience member, they don't get a player in the db.
+// Joining again keeps the key, so it's not a way to get another vote.
+func (r Router) Spectate(c *gin.Context, input *SpectateInput) (Spectator, error) {
+	key := ""
+	if cookie, err := c.Cookie("spectator"); err == nil {
+		if session, err := r.SessionService.ParseSpectator(cookie); err == nil {
+			key = session.Key
+		}
+	}
+	token, session, err := r.SessionService.IssueSpectator(key, input.Name)
+	if err != nil {
+		return Spectator{}, err
+	}
+	r.setCookie(c, "spectator", token, 0)
+	return Spectator{Key: session.Key, Name: session.Name}, nil
+}
+
+// SpectatorMiddleware gives every spectator connection its own id so it can get its own init message
+func (r Router) SpectatorMiddleware(c *gin.Context) {
+	cookie, err := c.Cookie("spectator")
+	if err != nil {
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Not a spectator. Try joining the audience again.", Status: 401})
+		return
+	}
+	session, err := r.SessionService.ParseSpectator(cookie)
+	if err != nil {
+		slog.Info("spectator cookie failed to be verified, unset it", "error", err)
+		r.setCookie(c, "spectator", "", -1)
+		c.AbortWithStatusJSON(401, ErrorResponse{Message: "Not a spectator. Try joining the audience again.", Status: 401})
+		return
+	}
+
+	c.Set("spectator", Spectator{Key: session.Key, Name: session.Name})
+	c.Set("player", db.Player{
+		Id:   stream.NewSpectatorId(),
+		Name: session.Name,
+	})
+	c.Next()
+}
+
+type PublicFieldsInput struct {
+	CharacterId int   `path:"characterId" json:"-" validate:"required,gt=0"`
+	Name        *bool `json:"name" validate:"required"`
+	Race        *bool `json:"race" validate:"required"`
+	Gender      *bool `json:"gender" validate:"required"`
+	Age         *bool `json:"age" validate:"required"`
+	Description *bool `json:"description" validate:"required"`
+	Appearance  *bool `json:"appearance" validate:"required"`
+	Portrait    *bool `json:"portrait" validate:"required"`
+}
+
+func (r Router) UpdatePublicFields(c *gin.Context, input *PublicFieldsInput) error {
+	fields, err := r.SpectatorService.UpdatePublicFields(db.CharacterReveleadFields{
+		CharacterId: input.CharacterId,
+		Name:        *input.Name,
+		Race:        *input.Race,
+		Gender:      *input.Gender,
+		Age:         *input.Age,
+		Description: *input.Description,
+		Appearance:  *input.Appearance,
+		Portrait:    *input.Portrait,
+	})
+	if err != nil {
+		return err
+	}
+	r.stream.SendAdminPublicFieldsMessage(fields)
+	r.SpectatorService.Sync(fields.CharacterId)
+	return nil
+}
+
+func (r Router) GetTable(c *gin.Context) ([]db.PlayerCharacter, error) {
+	if !r.SpectatorService.TableViewEnabled() {
+		return nil, fmt.Errorf("the table view is turned off")
+	}
+	characters, err := r.SpectatorService.GetTable()
+	if err != nil {
+		return nil, err
+	}
+	table := make([]db.PlayerCharacter, len(characters))
+	for i, character := range characters {
+		table[i] = character.ForPlayer()
+	}
+	return table, nil
+}
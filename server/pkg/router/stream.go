@@ -0,0 +1,68 @@
+package router
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+const maxReplyLength = 1000
+
+// GetStreamStats shows how far behind each connected client is
+func (r Router) GetStreamStats(c *gin.Context) ([]stream.ClientQueueStats, error) {
+	return r.stream.GetQueueStats(), nil
+}
+
+// webSocketHandler runs a stream handler without rendering what it returns.
+// The websocket has taken over the connection, so there's no response left to write to.
+func webSocketHandler(handler stream.StreamHandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, err := handler(c)
+		if err != nil {
+			slog.Error("websocket stream failed", "error", err)
+		}
+	}
+}
+
+// onClientMessage handles what players send back over their websocket.
+// There's nobody to answer, so anything that doesn't make sense is only logged.
+func (r *Router) onClientMessage(player db.Player, message stream.ClientMessage) {
+	if stream.IsAdmin(player.Id) || stream.IsSpectator(player.Id) {
+		slog.Info("ignoring websocket message that isn't from a player", "id", player.Id, "type", message.Type)
+		return
+	}
+	switch message.Type {
+	case "presence":
+		var status stream.PresenceStatus
+		json.Unmarshal(message.Data, &status)
+		switch status {
+		case stream.PresenceActive, stream.PresenceIdle, stream.PresenceAway:
+			r.stream.SendPlayerPresenceMessage(player.Id, status)
+		default:
+			slog.Info("unknown presence status", "playerId", player.Id, "status", status)
+		}
+	case "reply":
+		var reply struct {
+			Text string `json:"text"`
+		}
+		json.Unmarshal(message.Data, &reply)
+		text := strings.TrimSpace(reply.Text)
+		if text == "" || len(text) > maxReplyLength {
+			slog.Info("ignoring empty or too long reply", "playerId", player.Id, "length", len(text))
+			return
+		}
+		r.stream.SendPlayerReplyMessage(stream.PlayerReply{
+			PlayerId: player.Id,
+			Name:     player.Name,
+			Text:     text,
+			At:       time.Now().UnixMilli(),
+		})
+	default:
+		slog.Info("unknown websocket message", "playerId", player.Id, "type", message.Type)
+	}
+}
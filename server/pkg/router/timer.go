@@ -0,0 +1,46 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/services"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+func (r Router) GetTimers(c *gin.Context) ([]stream.Timer, error) {
+	return r.TimerService.GetAll(), nil
+}
+
+func (r Router) CreateTimer(c *gin.Context, input *services.CreateTimerInput) (stream.Timer, error) {
+	return r.TimerService.Create(*input)
+}
+
+type TimerInput struct {
+	TimerId int `uri:"timerId" binding:"required,gt=0"`
+}
+
+func (r Router) PauseTimer(c *gin.Context) (stream.Timer, error) {
+	var input TimerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return stream.Timer{}, err
+	}
+	return r.TimerService.Pause(input.TimerId)
+}
+
+func (r Router) ResumeTimer(c *gin.Context) (stream.Timer, error) {
+	var input TimerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return stream.Timer{}, err
+	}
+	return r.TimerService.Resume(input.TimerId)
+}
+
+func (r Router) CancelTimer(c *gin.Context) error {
+	var input TimerInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	return r.TimerService.Cancel(input.TimerId)
+}
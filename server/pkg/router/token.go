@@ -0,0 +1,32 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/services"
+)
+
+func (r Router) GetApiTokens(c *gin.Context) ([]db.ApiToken, error) {
+	return r.ApiTokenService.GetAll()
+}
+
+// CreateApiToken returns the token, it's the only time anyone sees it
+func (r Router) CreateApiToken(c *gin.Context, input *db.CreateApiTokenPayload) (services.CreatedApiToken, error) {
+	admin := c.MustGet("admin").(db.Admin)
+	return r.ApiTokenService.Create(*input, admin.Id)
+}
+
+type ApiTokenInput struct {
+	TokenId int `uri:"tokenId" binding:"required,gt=0"`
+}
+
+// RevokeApiToken works for the admin who made the token and for owners
+func (r Router) RevokeApiToken(c *gin.Context) error {
+	var input ApiTokenInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	admin := c.MustGet("admin").(db.Admin)
+	return r.ApiTokenService.Revoke(input.TokenId, admin)
+}
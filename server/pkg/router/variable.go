@@ -0,0 +1,35 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+type VariableInput struct {
+	Name  string `json:"name" binding:"required"`
+	Value string `json:"value"`
+}
+
+func (r Router) GetVariables(c *gin.Context) ([]db.Variable, error) {
+	return r.TemplateService.GetVariables()
+}
+
+func (r Router) SetVariable(c *gin.Context, input *VariableInput) (db.Variable, error) {
+	return r.TemplateService.SetVariable(db.Variable{
+		Name:  input.Name,
+		Value: input.Value,
+	})
+}
+
+type DeleteVariableInput struct {
+	Name string `uri:"name" binding:"required"`
+}
+
+func (r Router) DeleteVariable(c *gin.Context) error {
+	var input DeleteVariableInput
+	err := c.BindUri(&input)
+	if err != nil {
+		return err
+	}
+	return r.TemplateService.DeleteVariable(input.Name)
+}
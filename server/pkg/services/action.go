@@ -7,12 +7,14 @@ import (
 )
 
 type ActionService struct {
-	db db.Db
+	db    db.Db
+	rules *RuleService
 }
 
-func NewActionService(db db.Db) ActionService {
+func NewActionService(db db.Db, rules *RuleService) ActionService {
 	return ActionService{
-		db: db,
+		db:    db,
+		rules: rules,
 	}
 }
 
@@ -46,6 +48,26 @@ func (s *ActionService) Update(input db.Action) (int, db.Action, error) {
 }
 
 func (s *ActionService) Reveal(actionId int) (int, db.Action, error) {
+	return s.reveal(actionId, nil)
+}
+
+// Restore reveals an action again for undo and redo, without setting off any reveal rules
+func (s *ActionService) Restore(actionId int) (int, db.Action, error) {
+	return s.setRevealed(actionId)
+}
+
+func (s *ActionService) reveal(actionId int, chain ruleChain) (int, db.Action, error) {
+	playerId, action, err := s.setRevealed(actionId)
+	if err != nil || playerId == 0 {
+		return playerId, action, err
+	}
+	if s.rules != nil {
+		s.rules.actionRevealed(action.Id, chain)
+	}
+	return playerId, action, nil
+}
+
+func (s *ActionService) setRevealed(actionId int) (int, db.Action, error) {
 	action, err := s.db.Action.Get(actionId)
 	if err != nil {
 		slog.Error("Error getting action to reveal", "error", err)
@@ -94,6 +116,9 @@ func (s *ActionService) Hide(actionId int) (int, db.Action, error) {
 		slog.Error("Error unassigning action", "error", err)
 		return 0, db.Action{}, err
 	}
+	if character.PlayerId == nil {
+		return 0, action, nil
+	}
 	return *character.PlayerId, action, nil
 }
 
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 10
+
+type AdminService struct {
+	db db.Db
+	// ADMIN_KEY, only good for creating the first owner
+	setupKey string
+}
+
+func NewAdminService(db db.Db, setupKey string) AdminService {
+	return AdminService{
+		db:       db,
+		setupKey: setupKey,
+	}
+}
+
+// compared against when there's no account with the name, so a missing account takes as long as a wrong password
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// Login checks an admin's name and password
+func (s *AdminService) Login(name string, password string) (db.Admin, error) {
+	admin, hash, err := s.db.Admin.GetWithPasswordHash(name)
+	if err != nil || hash == "" {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return db.Admin{}, fmt.Errorf("wrong name or password")
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		return db.Admin{}, fmt.Errorf("wrong name or password")
+	}
+	return admin, nil
+}
+
+// Setup creates the first owner account. It needs the setup key and only works until there is an owner.
+// Owners from before passwords only have a key, setup gives them a password instead.
+func (s *AdminService) Setup(key string, name string, password string) (db.Admin, error) {
+	if s.setupKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(s.setupKey)) != 1 {
+		return db.Admin{}, fmt.Errorf("wrong setup key")
+	}
+	admins, err := s.GetAll()
+	if err != nil {
+		return db.Admin{}, err
+	}
+	hasOwner := false
+	for _, admin := range admins {
+		if admin.Role == db.AdminRoleOwner {
+			hasOwner = true
+		}
+	}
+	if !hasOwner {
+		return s.Create(db.CreateAdminPayload{
+			Name: name,
+			Role: db.AdminRoleOwner,
+		}, password)
+	}
+
+	// accounts that log in with OIDC have an email and no password on purpose, leave them alone
+	admin, hash, err := s.db.Admin.GetWithPasswordHash(name)
+	if err != nil || admin.Role != db.AdminRoleOwner || hash != "" || admin.Email != "" {
+		return db.Admin{}, fmt.Errorf("setup is already done, log in instead")
+	}
+	err = s.SetPassword(admin.Id, password)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	slog.Info("gave an owner from before passwords a password", "adminId", admin.Id)
+	return admin, nil
+}
+
+func (s *AdminService) Get(id int) (db.Admin, error) {
+	admin, err := s.db.Admin.Get(id)
+	if err != nil {
+		slog.Error("Error fetching admin", "error", err, "adminId", id)
+		return db.Admin{}, err
+	}
+	return admin, nil
+}
+
+func (s *AdminService) GetAll() ([]db.Admin, error) {
+	admins, err := s.db.Admin.GetAll()
+	if err != nil {
+		slog.Error("Error fetching admins", "error", err)
+		return []db.Admin{}, err
+	}
+	return admins, nil
+}
+
+// Create adds an admin. Admins with an email can go without a password and only log in with OIDC.
+// Names have to be unique, they're what admins log in with.
+func (s *AdminService) Create(input db.CreateAdminPayload, password string) (db.Admin, error) {
+	admins, err := s.GetAll()
+	if err != nil {
+		return db.Admin{}, err
+	}
+	for _, admin := range admins {
+		if strings.EqualFold(admin.Name, input.Name) {
+			return db.Admin{}, fmt.Errorf("there's already an admin called %s", admin.Name)
+		}
+	}
+
+	hash := ""
+	if password != "" || input.Email == "" {
+		hash, err = hashPassword(password)
+		if err != nil {
+			return db.Admin{}, err
+		}
+	}
+	admin, err := s.db.Admin.Create(input, hash)
+	if err != nil {
+		slog.Error("Error creating admin", "error", err)
+		return db.Admin{}, err
+	}
+	return admin, nil
+}
+
+func (s *AdminService) SetPassword(id int, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	err = s.db.Admin.SetPasswordHash(id, hash)
+	if err != nil {
+		slog.Error("Error setting admin password", "error", err, "adminId", id)
+		return err
+	}
+	return nil
+}
+
+func (s *AdminService) SetRole(id int, role db.AdminRole) (db.Admin, error) {
+	admin, err := s.db.Admin.Get(id)
+	if err != nil {
+		slog.Error("Error fetching admin", "error", err, "adminId", id)
+		return db.Admin{}, err
+	}
+	if admin.Role == db.AdminRoleOwner && role != db.AdminRoleOwner {
+		err = s.ensureAnotherOwner(id)
+		if err != nil {
+			return db.Admin{}, err
+		}
+	}
+	admin, err = s.db.Admin.SetRole(id, role)
+	if err != nil {
+		slog.Error("Error setting admin role", "error", err, "adminId", id)
+		return db.Admin{}, err
+	}
+	return admin, nil
+}
+
+func (s *AdminService) Delete(id int) error {
+	admin, err := s.db.Admin.Get(id)
+	if err != nil {
+		slog.Error("Error fetching admin", "error", err, "adminId", id)
+		return err
+	}
+	if admin.Role == db.AdminRoleOwner {
+		err = s.ensureAnotherOwner(id)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.db.Admin.Delete(id)
+	if err != nil {
+		slog.Error("Error deleting admin", "error", err, "adminId", id)
+		return err
+	}
+	return nil
+}
+
+// the game always needs someone who can manage it
+func (s *AdminService) ensureAnotherOwner(id int) error {
+	admins, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		if admin.Id != id && admin.Role == db.AdminRoleOwner {
+			return nil
+		}
+	}
+	return fmt.Errorf("can't remove the last owner")
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("passwords need at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
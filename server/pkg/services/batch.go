@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+// BatchOperation reveals or hides either an action or a single character field
+type BatchOperation struct {
+	ActionId    *int   `json:"actionId"`
+	CharacterId *int   `json:"characterId"`
+	Field       string `json:"field"`
+	Reveal      bool   `json:"reveal"`
+}
+
+type BatchService struct {
+	db         db.Db
+	actions    ActionService
+	characters CharacterService
+	templates  TemplateService
+}
+
+func NewBatchService(db db.Db, actions ActionService, characters CharacterService) BatchService {
+	return BatchService{
+		db:         db,
+		actions:    actions,
+		characters: characters,
+		templates:  NewTemplateService(db),
+	}
+}
+
+// Apply runs all the operations and collects what changed so each player gets a single message.
+// Every action, character and field is looked up before anything is changed so a typo doesn't leave a twist half revealed.
+// There's no transaction though, if saving fails partway through, what was already saved stays that way.
+func (s *BatchService) Apply(ops []BatchOperation) (stream.AdminBatchMessageData, map[int]stream.BatchMessageData, error) {
+	admin := stream.AdminBatchMessageData{
+		Actions:    make([]db.Action, 0),
+		Characters: make([]stream.AdminCharacterMessageData, 0),
+	}
+	players := make(map[int]stream.BatchMessageData)
+
+	fieldOps := make(map[int][]BatchOperation)
+	revealedFields := make(map[int]db.CharacterReveleadFields)
+	characterOrder := make([]int, 0)
+	for i, op := range ops {
+		if op.ActionId != nil && op.CharacterId == nil {
+			action, err := s.db.Action.Get(*op.ActionId)
+			if err != nil || action.Id == 0 {
+				slog.Error("error getting action for batch", "error", err, "actionId", *op.ActionId)
+				return admin, players, fmt.Errorf("operation %d: action %d not found", i, *op.ActionId)
+			}
+			continue
+		}
+		if op.ActionId == nil && op.CharacterId != nil {
+			if !setRevealedField(&db.CharacterReveleadFields{}, op.Field, op.Reveal) {
+				return admin, players, fmt.Errorf("operation %d: unknown character field %q", i, op.Field)
+			}
+			if _, ok := fieldOps[*op.CharacterId]; !ok {
+				character, err := s.db.Character.Get(*op.CharacterId)
+				if err != nil || character.Id == 0 {
+					slog.Error("error getting character for batch", "error", err, "characterId", *op.CharacterId)
+					return admin, players, fmt.Errorf("operation %d: character %d not found", i, *op.CharacterId)
+				}
+				fields, err := s.db.Character.GetRevealedFields(*op.CharacterId)
+				if err != nil {
+					slog.Error("error getting revealed fields for batch", "error", err, "characterId", *op.CharacterId)
+					return admin, players, err
+				}
+				revealedFields[*op.CharacterId] = fields
+				characterOrder = append(characterOrder, *op.CharacterId)
+			}
+			fieldOps[*op.CharacterId] = append(fieldOps[*op.CharacterId], op)
+			continue
+		}
+		return admin, players, fmt.Errorf("operation %d needs either an actionId or a characterId and field", i)
+	}
+
+	player := func(id int) stream.BatchMessageData {
+		data, ok := players[id]
+		if !ok {
+			data = stream.BatchMessageData{
+				Actions:        make([]db.PlayerAction, 0),
+				DeletedActions: make([]int, 0),
+				Characters:     make([]db.PlayerCharacterWithActions, 0),
+			}
+		}
+		return data
+	}
+
+	for _, op := range ops {
+		if op.ActionId == nil {
+			continue
+		}
+		if op.Reveal {
+			playerId, action, err := s.actions.Reveal(*op.ActionId)
+			if err != nil {
+				return admin, players, err
+			}
+			if playerId == 0 {
+				continue
+			}
+			rendered, err := s.templates.RenderAction(action)
+			if err != nil {
+				return admin, players, err
+			}
+			admin.Actions = append(admin.Actions, action)
+			data := player(playerId)
+			data.Actions = append(data.Actions, rendered.ForPlayer())
+			players[playerId] = data
+		} else {
+			playerId, action, err := s.actions.Hide(*op.ActionId)
+			if err != nil {
+				return admin, players, err
+			}
+			if action.Id == 0 {
+				continue
+			}
+			admin.Actions = append(admin.Actions, action)
+			if playerId == 0 {
+				continue
+			}
+			data := player(playerId)
+			data.DeletedActions = append(data.DeletedActions, action.Id)
+			players[playerId] = data
+		}
+	}
+
+	for _, characterId := range characterOrder {
+		fields := revealedFields[characterId]
+		for _, op := range fieldOps[characterId] {
+			setRevealedField(&fields, op.Field, op.Reveal)
+		}
+		character, fields, err := s.characters.UpdateRevealedFields(fields)
+		if err != nil {
+			return admin, players, err
+		}
+		admin.Characters = append(admin.Characters, stream.AdminCharacterMessageData{
+			Character: character,
+			Fields:    fields,
+		})
+		if character.PlayerId == nil {
+			continue
+		}
+		redacted, err := s.characters.Redact(character)
+		if err != nil {
+			return admin, players, err
+		}
+		data := player(*character.PlayerId)
+		data.Characters = append(data.Characters, redacted.ForPlayer())
+		players[*character.PlayerId] = data
+	}
+
+	return admin, players, nil
+}
@@ -9,14 +9,18 @@ import (
 )
 
 type CharacterService struct {
-	db     db.Db
-	stream stream.StreamingServer
+	db        db.Db
+	stream    stream.StreamingServer
+	templates TemplateService
+	rules     *RuleService
 }
 
-func NewCharacterService(db db.Db, stream stream.StreamingServer) CharacterService {
+func NewCharacterService(db db.Db, stream stream.StreamingServer, rules *RuleService) CharacterService {
 	return CharacterService{
-		db:     db,
-		stream: stream,
+		db:        db,
+		stream:    stream,
+		templates: NewTemplateService(db),
+		rules:     rules,
 	}
 }
 
@@ -96,6 +100,10 @@ func (s *CharacterService) GetAllAssignedWithActionsRedacted(playerId int) ([]db
 			slog.Error("error getting revealed fields for character", "error", err, "characterId", character.Id)
 			return []db.CharacterWithActions{}, err
 		}
+		actions, err = s.templates.RenderActions(character, fields, actions)
+		if err != nil {
+			return []db.CharacterWithActions{}, err
+		}
 		redactCharacter(&character, fields)
 		charsWithActions[i] = db.CharacterWithActions{
 			Character: character,
@@ -116,8 +124,13 @@ func (s *CharacterService) Redact(character db.CharacterWithActions) (db.Charact
 		if !action.Revealed {
 			continue
 		}
+		action.Notes = ""
 		actions = append(actions, action)
 	}
+	actions, err = s.templates.RenderActions(character.Character, fields, actions)
+	if err != nil {
+		return db.CharacterWithActions{}, err
+	}
 	redactCharacter(&character.Character, fields)
 	redacted := db.CharacterWithActions{
 		Character: character.Character,
@@ -145,6 +158,67 @@ func redactCharacter(character *db.Character, revealedFields db.CharacterRevelea
 	if !revealedFields.Appearance {
 		character.Appearance = ""
 	}
+	if !revealedFields.Portrait {
+		character.Portrait = ""
+		character.PortraitThumbnail = ""
+	}
+	// notes are never revealed
+	character.Notes = ""
+}
+
+var revealableFields = []string{"name", "race", "gender", "age", "description", "appearance", "portrait"}
+
+// setRevealedField flips a field by its json name, returns false for unknown fields
+func setRevealedField(fields *db.CharacterReveleadFields, field string, revealed bool) bool {
+	switch field {
+	case "name":
+		fields.Name = revealed
+	case "race":
+		fields.Race = revealed
+	case "gender":
+		fields.Gender = revealed
+	case "age":
+		fields.Age = revealed
+	case "description":
+		fields.Description = revealed
+	case "appearance":
+		fields.Appearance = revealed
+	case "portrait":
+		fields.Portrait = revealed
+	default:
+		return false
+	}
+	return true
+}
+
+func isFieldRevealed(fields db.CharacterReveleadFields, field string) bool {
+	switch field {
+	case "name":
+		return fields.Name
+	case "race":
+		return fields.Race
+	case "gender":
+		return fields.Gender
+	case "age":
+		return fields.Age
+	case "description":
+		return fields.Description
+	case "appearance":
+		return fields.Appearance
+	case "portrait":
+		return fields.Portrait
+	}
+	return false
+}
+
+func newlyRevealedFields(prev db.CharacterReveleadFields, next db.CharacterReveleadFields) []string {
+	revealed := make([]string, 0)
+	for _, field := range revealableFields {
+		if !isFieldRevealed(prev, field) && isFieldRevealed(next, field) {
+			revealed = append(revealed, field)
+		}
+	}
+	return revealed
 }
 
 func (s *CharacterService) Assign(characterId int, playerId int) (*int, db.CharacterWithActions, error) {
@@ -217,28 +291,57 @@ func (s *CharacterService) Unassign(characterId int) (int, db.CharacterWithActio
 }
 
 func (s *CharacterService) UpdateRevealedFields(input db.CharacterReveleadFields) (db.CharacterWithActions, db.CharacterReveleadFields, error) {
+	return s.updateRevealedFields(input, nil)
+}
+
+// RestoreRevealedFields sets the revealed fields for undo and redo, without setting off any reveal rules
+func (s *CharacterService) RestoreRevealedFields(input db.CharacterReveleadFields) (db.CharacterWithActions, db.CharacterReveleadFields, error) {
+	character, fields, _, err := s.setRevealedFields(input)
+	return character, fields, err
+}
+
+func (s *CharacterService) updateRevealedFields(input db.CharacterReveleadFields, chain ruleChain) (db.CharacterWithActions, db.CharacterReveleadFields, error) {
+	character, fields, prevFields, err := s.setRevealedFields(input)
+	if err != nil {
+		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, err
+	}
+	if s.rules != nil {
+		s.rules.fieldsRevealed(character.Id, newlyRevealedFields(prevFields, fields), chain)
+	}
+	return character, fields, nil
+}
+
+// setRevealedFields saves the fields and returns what they were before
+func (s *CharacterService) setRevealedFields(input db.CharacterReveleadFields) (db.CharacterWithActions, db.CharacterReveleadFields, db.CharacterReveleadFields, error) {
+	prevFields, err := s.db.Character.GetRevealedFields(input.CharacterId)
+	if err != nil {
+		slog.Error("error getting revealed fields to update", "error", err, "characterId", input.CharacterId)
+		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, db.CharacterReveleadFields{}, err
+	}
+
 	fields, err := s.db.Character.UpdateRevealedFields(input)
 	if err != nil {
 		slog.Error("error getting character to update", "error", err, "characterId", input.CharacterId)
-		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, err
+		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, db.CharacterReveleadFields{}, err
 	}
 
 	character, err := s.db.Character.Get(fields.CharacterId)
 	if err != nil {
 		slog.Error("error getting character to update", "error", err, "characterId", input.CharacterId)
-		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, err
+		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, db.CharacterReveleadFields{}, err
 	}
 
 	actions, err := s.db.Action.GetAll(character.Id)
 	if err != nil {
 		slog.Error("error getting actions for character", "error", err, "characterId", character.Id)
-		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, err
+		return db.CharacterWithActions{}, db.CharacterReveleadFields{}, db.CharacterReveleadFields{}, err
 	}
 	withActions := db.CharacterWithActions{
 		Character: character,
 		Actions:   actions,
 	}
-	return withActions, fields, nil
+
+	return withActions, fields, prevFields, nil
 }
 
 func (s *CharacterService) Delete(id int) error {
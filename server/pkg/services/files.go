@@ -0,0 +1,38 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// randomFileName makes an unguessable file name so uploads can't be found by counting ids.
+func randomFileName(prefix string, ext string) string {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%s%s", prefix, hex.EncodeToString(suffix), ext)
+}
+
+// detectUploadType checks the upload against a size limit and a map of allowed
+// content types to file extensions, returning the type and extension.
+func detectUploadType(data []byte, maxBytes int, allowed map[string]string) (string, string, error) {
+	if len(data) > maxBytes {
+		return "", "", fmt.Errorf("file is larger than %d bytes", maxBytes)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := allowed[contentType]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported file type %q", contentType)
+	}
+	return contentType, ext, nil
+}
+
+func ensureDir(dir string) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		slog.Error("Error creating upload directory", "error", err, "dir", dir)
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+const (
+	MaxHandoutBytes  = 20 << 20
+	handoutUrlPrefix = "/handout-files"
+)
+
+var allowedHandoutTypes = map[string]string{
+	"image/png":                    ".png",
+	"image/jpeg":                   ".jpg",
+	"image/gif":                    ".gif",
+	"image/webp":                   ".webp",
+	"application/pdf":              ".pdf",
+	"text/plain; charset=utf-8":    ".txt",
+	"text/plain; charset=utf-16be": ".txt",
+	"text/plain; charset=utf-16le": ".txt",
+}
+
+type HandoutService struct {
+	db  db.Db
+	dir string
+}
+
+func NewHandoutService(db db.Db, dir string) HandoutService {
+	dir = filepath.Join(dir, "handouts")
+	ensureDir(dir)
+	return HandoutService{
+		db:  db,
+		dir: dir,
+	}
+}
+
+func (s *HandoutService) Create(name string, data []byte) (db.Handout, error) {
+	contentType, ext, err := detectUploadType(data, MaxHandoutBytes, allowedHandoutTypes)
+	if err != nil {
+		return db.Handout{}, err
+	}
+	file := randomFileName("handout", ext)
+	err = os.WriteFile(filepath.Join(s.dir, file), data, 0o644)
+	if err != nil {
+		slog.Error("Error writing handout", "error", err)
+		return db.Handout{}, err
+	}
+	handout, err := s.db.Handout.Create(db.CreateHandoutPayload{
+		Name:        name,
+		Url:         fmt.Sprintf("%s/%s", handoutUrlPrefix, file),
+		ContentType: contentType,
+	})
+	if err != nil {
+		slog.Error("Error creating handout", "error", err)
+		os.Remove(filepath.Join(s.dir, file))
+		return db.Handout{}, err
+	}
+	return handout, nil
+}
+
+func (s *HandoutService) GetAll() ([]db.Handout, error) {
+	handouts, err := s.db.Handout.GetAll()
+	if err != nil {
+		slog.Error("Error fetching handouts", "error", err)
+		return []db.Handout{}, err
+	}
+	return handouts, nil
+}
+
+func (s *HandoutService) GetAllRevealedRedacted(playerId int) ([]db.Handout, error) {
+	handouts, err := s.db.Handout.GetAllRevealed(playerId)
+	if err != nil {
+		slog.Error("Error fetching handouts for player", "error", err, "playerId", playerId)
+		return []db.Handout{}, err
+	}
+	for i := range handouts {
+		handouts[i].PlayerIds = nil
+	}
+	return handouts, nil
+}
+
+// Reveal gives a single player access to the handout.
+// Returns the players that need to be told about it, which is nobody if they could already see it.
+func (s *HandoutService) Reveal(handoutId int, playerId int) ([]int, db.Handout, error) {
+	handout, err := s.db.Handout.Get(handoutId)
+	if err != nil {
+		slog.Error("Error getting handout to reveal", "error", err)
+		return nil, db.Handout{}, err
+	}
+	if handout.Everyone || slices.Contains(handout.PlayerIds, playerId) {
+		slog.Info("already revealed", "handoutId", handoutId, "playerId", playerId)
+		return nil, handout, nil
+	}
+	handout.PlayerIds = append(handout.PlayerIds, playerId)
+	handout, err = s.db.Handout.Update(handout)
+	if err != nil {
+		slog.Error("Error revealing handout", "error", err)
+		return nil, db.Handout{}, err
+	}
+	return []int{playerId}, handout, nil
+}
+
+func (s *HandoutService) RevealToAll(handoutId int) ([]int, db.Handout, error) {
+	handout, err := s.db.Handout.Get(handoutId)
+	if err != nil {
+		slog.Error("Error getting handout to reveal", "error", err)
+		return nil, db.Handout{}, err
+	}
+	if handout.Everyone {
+		slog.Info("already revealed to everyone", "handoutId", handoutId)
+		return nil, handout, nil
+	}
+	handout.Everyone = true
+	handout, err = s.db.Handout.Update(handout)
+	if err != nil {
+		slog.Error("Error revealing handout", "error", err)
+		return nil, db.Handout{}, err
+	}
+	return []int{stream.AllPlayersId}, handout, nil
+}
+
+// Hide takes away a single player's access to the handout.
+// A handout revealed to everyone stays visible to them until it is hidden from everyone.
+func (s *HandoutService) Hide(handoutId int, playerId int) ([]int, db.Handout, error) {
+	handout, err := s.db.Handout.Get(handoutId)
+	if err != nil {
+		slog.Error("Error getting handout to hide", "error", err)
+		return nil, db.Handout{}, err
+	}
+	if !slices.Contains(handout.PlayerIds, playerId) {
+		slog.Info("already hidden", "handoutId", handoutId, "playerId", playerId)
+		return nil, handout, nil
+	}
+	handout.PlayerIds = slices.DeleteFunc(handout.PlayerIds, func(id int) bool {
+		return id == playerId
+	})
+	handout, err = s.db.Handout.Update(handout)
+	if err != nil {
+		slog.Error("Error hiding handout", "error", err)
+		return nil, db.Handout{}, err
+	}
+	if handout.Everyone {
+		return nil, handout, nil
+	}
+	return []int{playerId}, handout, nil
+}
+
+func (s *HandoutService) HideFromAll(handoutId int) ([]int, db.Handout, error) {
+	handout, err := s.db.Handout.Get(handoutId)
+	if err != nil {
+		slog.Error("Error getting handout to hide", "error", err)
+		return nil, db.Handout{}, err
+	}
+	handout.Everyone = false
+	handout.PlayerIds = make([]int, 0)
+	handout, err = s.db.Handout.Update(handout)
+	if err != nil {
+		slog.Error("Error hiding handout", "error", err)
+		return nil, db.Handout{}, err
+	}
+	return []int{stream.AllPlayersId}, handout, nil
+}
+
+// Path returns the file on disk for the handout if the player has been given access to it.
+func (s *HandoutService) Path(file string, playerId int, isAdmin bool) (string, db.Handout, error) {
+	var handouts []db.Handout
+	var err error
+	if isAdmin {
+		handouts, err = s.db.Handout.GetAll()
+	} else {
+		handouts, err = s.db.Handout.GetAllRevealed(playerId)
+	}
+	if err != nil {
+		slog.Error("Error fetching handouts", "error", err)
+		return "", db.Handout{}, err
+	}
+	url := fmt.Sprintf("%s/%s", handoutUrlPrefix, filepath.Base(file))
+	for _, handout := range handouts {
+		if handout.Url == url {
+			return filepath.Join(s.dir, filepath.Base(file)), handout, nil
+		}
+	}
+	return "", db.Handout{}, fmt.Errorf("handout not found")
+}
+
+func (s *HandoutService) Delete(id int) error {
+	handout, err := s.db.Handout.Get(id)
+	if err != nil {
+		slog.Error("Error getting handout to delete", "error", err)
+		return err
+	}
+	err = s.db.Handout.Delete(id)
+	if err != nil {
+		slog.Error("Error deleting handout", "error", err)
+		return err
+	}
+	os.Remove(filepath.Join(s.dir, filepath.Base(handout.Url)))
+	return nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+const maxHistory = 50
+
+const (
+	HistoryRevealAction = "reveal-action"
+	HistoryHideAction   = "hide-action"
+	HistoryAssign       = "assign"
+	HistoryUnassign     = "unassign"
+	HistoryFields       = "fields"
+)
+
+// HistoryEntry has what's needed to both redo and undo a single GM operation
+type HistoryEntry struct {
+	Type        string `json:"type"`
+	ActionId    int    `json:"actionId,omitempty"`
+	CharacterId int    `json:"characterId,omitempty"`
+	PlayerId    int    `json:"playerId,omitempty"`
+	// player the character was assigned to before an assign or unassign
+	PrevPlayerId *int `json:"prevPlayerId,omitempty"`
+	// actions that unassigning a character hid
+	HiddenActionIds []int                      `json:"hiddenActionIds,omitempty"`
+	Fields          db.CharacterReveleadFields `json:"fields"`
+	PrevFields      db.CharacterReveleadFields `json:"prevFields"`
+}
+
+type history struct {
+	undo []HistoryEntry
+	redo []HistoryEntry
+}
+
+// HistoryService keeps an undo and redo stack per GM session in memory.
+// History doesn't survive a restart, that's fine for undoing a misclick.
+type HistoryService struct {
+	mu       sync.Mutex
+	sessions map[string]*history
+}
+
+func NewHistoryService() *HistoryService {
+	return &HistoryService{
+		sessions: make(map[string]*history),
+	}
+}
+
+// Record adds a new operation, which throws away anything that could have been redone
+func (s *HistoryService) Record(session string, entry HistoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.get(session)
+	h.undo = append(h.undo, entry)
+	if len(h.undo) > maxHistory {
+		h.undo = h.undo[len(h.undo)-maxHistory:]
+	}
+	h.redo = nil
+}
+
+// PopUndo takes the last operation, it goes on the redo stack once it's been reversed
+func (s *HistoryService) PopUndo(session string) (HistoryEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.get(session)
+	if len(h.undo) == 0 {
+		return HistoryEntry{}, false
+	}
+	entry := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	return entry, true
+}
+
+func (s *HistoryService) PopRedo(session string) (HistoryEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.get(session)
+	if len(h.redo) == 0 {
+		return HistoryEntry{}, false
+	}
+	entry := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	return entry, true
+}
+
+func (s *HistoryService) PushUndo(session string, entry HistoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.get(session)
+	h.undo = append(h.undo, entry)
+}
+
+func (s *HistoryService) PushRedo(session string, entry HistoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.get(session)
+	h.redo = append(h.redo, entry)
+}
+
+func (s *HistoryService) get(session string) *history {
+	h, ok := s.sessions[session]
+	if !ok {
+		h = &history{}
+		s.sessions[session] = h
+	}
+	return h
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+const maxLoginFailures = 5
+const loginFailureWindow = 15 * time.Minute
+const loginLockout = 15 * time.Minute
+
+type loginFailures struct {
+	count int
+	first time.Time
+	// zero unless locked out
+	lockedUntil time.Time
+}
+
+// LoginLimiter counts failed logins per IP and per account,
+// and locks either out for a while once there are too many.
+type LoginLimiter struct {
+	mu       sync.Mutex
+	ips      map[string]*loginFailures
+	accounts map[string]*loginFailures
+}
+
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{
+		ips:      make(map[string]*loginFailures),
+		accounts: make(map[string]*loginFailures),
+	}
+}
+
+// Attempt reserves a login attempt before the credentials are checked, it counts as a failure until Succeed says otherwise.
+// Checking a password is slow, so guesses that come in at the same time would all get through
+// if they were only counted once they failed.
+// It's false, with when they can try again, while the IP or account is locked out.
+func (l *LoginLimiter) Attempt(ip string, account string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	prune(l.ips, now)
+	prune(l.accounts, now)
+	until := locked(l.ips[ip], now)
+	if accountUntil := locked(l.accounts[account], now); accountUntil.After(until) {
+		until = accountUntil
+	}
+	if !until.IsZero() {
+		return until, false
+	}
+	reserve(l.ips, ip, now)
+	reserve(l.accounts, account, now)
+	return time.Time{}, true
+}
+
+// Succeed takes back the attempt once the right credentials come through.
+// The account starts over, but the IP keeps its other failures,
+// one good login shouldn't wipe out guesses at other accounts.
+func (l *LoginLimiter) Succeed(ip string, account string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.accounts, account)
+	if current, ok := l.ips[ip]; ok && current.count > 0 {
+		current.count--
+	}
+}
+
+// locked starts the lockout once all the attempts are used up, and returns when it's over, zero if it isn't locked
+func locked(current *loginFailures, now time.Time) time.Time {
+	if current == nil {
+		return time.Time{}
+	}
+	if current.count >= maxLoginFailures && !current.lockedUntil.After(now) {
+		current.lockedUntil = now.Add(loginLockout)
+		// start counting again once the lockout is over
+		current.count = 0
+		current.first = current.lockedUntil
+	}
+	if !current.lockedUntil.After(now) {
+		return time.Time{}
+	}
+	return current.lockedUntil
+}
+
+func reserve(failures map[string]*loginFailures, key string, now time.Time) {
+	current, ok := failures[key]
+	if !ok || now.Sub(current.first) > loginFailureWindow {
+		current = &loginFailures{first: now}
+		failures[key] = current
+	}
+	current.count++
+}
+
+// forget about failures that don't count anymore, so the maps don't grow forever
+func prune(failures map[string]*loginFailures, now time.Time) {
+	for key, current := range failures {
+		if now.Sub(current.first) > loginFailureWindow && !current.lockedUntil.After(now) {
+			delete(failures, key)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoginLimiterLocksOutAfterMaxFailures(t *testing.T) {
+	limiter := NewLoginLimiter()
+	for i := 0; i < maxLoginFailures; i++ {
+		if _, ok := limiter.Attempt("1.2.3.4", "gm"); !ok {
+			t.Fatalf("attempt %d should have been allowed", i+1)
+		}
+	}
+	until, ok := limiter.Attempt("1.2.3.4", "gm")
+	if ok || until.IsZero() {
+		t.Fatal("expected a lockout after too many failures")
+	}
+	// the account is locked from anywhere
+	if _, ok := limiter.Attempt("5.6.7.8", "gm"); ok {
+		t.Fatal("expected the account to be locked from another IP")
+	}
+}
+
+func TestLoginLimiterConcurrentAttempts(t *testing.T) {
+	limiter := NewLoginLimiter()
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, ok := limiter.Attempt(fmt.Sprintf("10.0.0.%d", i), "gm"); ok {
+				allowed.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if allowed.Load() != maxLoginFailures {
+		t.Fatalf("expected %d guesses to get through, got %d", maxLoginFailures, allowed.Load())
+	}
+}
+
+func TestLoginLimiterSucceedOnlyClearsTheAccount(t *testing.T) {
+	limiter := NewLoginLimiter()
+	// guesses at other accounts from the same IP
+	for i := 0; i < maxLoginFailures-1; i++ {
+		limiter.Attempt("1.2.3.4", fmt.Sprintf("guess-%d", i))
+	}
+
+	if _, ok := limiter.Attempt("1.2.3.4", "gm"); !ok {
+		t.Fatal("expected the login to be allowed")
+	}
+	limiter.Succeed("1.2.3.4", "gm")
+
+	// the good login didn't wipe out the guesses, one more and the IP is locked
+	if _, ok := limiter.Attempt("1.2.3.4", "another-guess"); !ok {
+		t.Fatal("expected one more attempt to be allowed")
+	}
+	if _, ok := limiter.Attempt("1.2.3.4", "yet-another-guess"); ok {
+		t.Fatal("expected the IP to be locked out")
+	}
+}
+
+func TestLoginLimiterSuccessesDontCount(t *testing.T) {
+	limiter := NewLoginLimiter()
+	for i := 0; i < maxLoginFailures*2; i++ {
+		if _, ok := limiter.Attempt("1.2.3.4", "gm"); !ok {
+			t.Fatalf("login %d should have been allowed", i+1)
+		}
+		limiter.Succeed("1.2.3.4", "gm")
+	}
+}
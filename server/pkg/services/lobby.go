@@ -0,0 +1,85 @@
+package services
+
+import (
+	"crypto/rand"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+// no 0/O or 1/I, the code gets read out loud at the table
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+const joinCodeLength = 6
+const joinCodeSetting = "join-code"
+
+// LobbyService holds the join code for the current session, new players need it to log in.
+// It's kept in the settings table so the lobby stays open when the machine stops and starts again
+// in the middle of a session.
+type LobbyService struct {
+	db   db.Db
+	mu   sync.Mutex
+	code string
+}
+
+func NewLobbyService(db db.Db) *LobbyService {
+	code, err := db.Setting.Get(joinCodeSetting)
+	if err != nil {
+		// the GM can open it again
+		slog.Error("Error loading the join code, the lobby starts closed", "error", err)
+		code = ""
+	}
+	return &LobbyService{
+		db:   db,
+		code: code,
+	}
+}
+
+// Code is the current join code, empty when nobody new can join
+func (s *LobbyService) Code() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.code
+}
+
+// Open starts a new session with a fresh join code, the old one stops working
+func (s *LobbyService) Open() (string, error) {
+	random := make([]byte, joinCodeLength)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", err
+	}
+	code := make([]byte, joinCodeLength)
+	for i, b := range random {
+		code[i] = joinCodeAlphabet[int(b)%len(joinCodeAlphabet)]
+	}
+
+	err = s.set(string(code))
+	if err != nil {
+		return "", err
+	}
+	return string(code), nil
+}
+
+func (s *LobbyService) Close() error {
+	return s.set("")
+}
+
+func (s *LobbyService) set(code string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Setting.Set(db.Setting{Key: joinCodeSetting, Value: code})
+	if err != nil {
+		slog.Error("Error saving the join code", "error", err)
+		return err
+	}
+	s.code = code
+	return nil
+}
+
+func (s *LobbyService) Check(code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.code != "" && strings.EqualFold(strings.TrimSpace(code), s.code)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"golang.org/x/oauth2"
+)
+
+type OidcConfig struct {
+	// OIDC login is off without an issuer. Any issuer with discovery works, including a local mock one.
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	// where the provider sends the GM back to, ends in /admin/oidc/callback
+	RedirectURL string
+}
+
+// OidcLogin is what has to survive the trip to the provider and back
+type OidcLogin struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// OidcService logs GMs in with an OpenID Connect provider
+type OidcService struct {
+	db     db.Db
+	config OidcConfig
+
+	// discovered the first time it's needed, so the server starts even if the provider is down
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOidcService(db db.Db, config OidcConfig) *OidcService {
+	return &OidcService{
+		db:     db,
+		config: config,
+	}
+}
+
+func (s *OidcService) Enabled() bool {
+	return s.config.Issuer != ""
+}
+
+// Start begins a login, returning the provider url to send the GM to
+func (s *OidcService) Start(ctx context.Context) (string, OidcLogin, error) {
+	oauth, _, err := s.oauth(ctx)
+	if err != nil {
+		return "", OidcLogin{}, err
+	}
+	login := OidcLogin{
+		State:    oauth2.GenerateVerifier(),
+		Nonce:    oauth2.GenerateVerifier(),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+	url := oauth.AuthCodeURL(login.State, oidc.Nonce(login.Nonce), oauth2.S256ChallengeOption(login.Verifier))
+	return url, login, nil
+}
+
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// Finish trades the code for an id token and finds the admin it belongs to.
+// An identity that isn't linked yet is linked to the admin with its verified email.
+func (s *OidcService) Finish(ctx context.Context, login OidcLogin, state string, code string) (db.Admin, error) {
+	if state == "" || state != login.State {
+		return db.Admin{}, fmt.Errorf("the login didn't match, try again")
+	}
+	oauth, provider, err := s.oauth(ctx)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return db.Admin{}, fmt.Errorf("couldn't exchange the code: %w", err)
+	}
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return db.Admin{}, fmt.Errorf("the provider didn't send an id token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.ClientId}).Verify(ctx, rawIdToken)
+	if err != nil {
+		return db.Admin{}, fmt.Errorf("invalid id token: %w", err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return db.Admin{}, fmt.Errorf("the login didn't match, try again")
+	}
+
+	admin, err := s.db.Admin.GetByOidcSubject(idToken.Subject)
+	if err == nil {
+		return admin, nil
+	}
+
+	var claims oidcClaims
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return db.Admin{}, err
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		slog.Warn("OIDC login with no linked admin and no verified email", "subject", idToken.Subject)
+		return db.Admin{}, fmt.Errorf("there's no GM account for you")
+	}
+	admin, err = s.db.Admin.GetUnlinkedByEmail(claims.Email)
+	if err != nil {
+		slog.Warn("OIDC login with no admin for the email", "subject", idToken.Subject, "email", claims.Email)
+		return db.Admin{}, fmt.Errorf("there's no GM account for you")
+	}
+	err = s.db.Admin.SetOidcSubject(admin.Id, idToken.Subject)
+	if err != nil {
+		slog.Error("Error linking OIDC identity", "error", err, "adminId", admin.Id)
+		return db.Admin{}, err
+	}
+	slog.Info("linked OIDC identity to admin", "adminId", admin.Id, "subject", idToken.Subject)
+	return admin, nil
+}
+
+func (s *OidcService) oauth(ctx context.Context) (oauth2.Config, *oidc.Provider, error) {
+	if !s.Enabled() {
+		return oauth2.Config{}, nil, fmt.Errorf("OIDC login is turned off")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.provider == nil {
+		provider, err := oidc.NewProvider(ctx, s.config.Issuer)
+		if err != nil {
+			slog.Error("Error discovering OIDC provider", "error", err, "issuer", s.config.Issuer)
+			return oauth2.Config{}, nil, err
+		}
+		s.provider = provider
+	}
+	return oauth2.Config{
+		ClientID:     s.config.ClientId,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Endpoint:     s.provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	}, s.provider, nil
+}
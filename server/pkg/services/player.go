@@ -19,8 +19,9 @@ func NewPlayerService(db db.Db, stream stream.StreamingServer) PlayerService {
 	}
 }
 
+// Create adds a new player to the lobby, they can't play until the GM approves them
 func (s *PlayerService) Create(name string) (db.Player, error) {
-	player, err := s.db.Player.Create(db.CreatePlayerPayload{Name: name})
+	player, err := s.db.Player.Create(db.CreatePlayerPayload{Name: name, Status: db.PlayerStatusPending})
 	if err != nil {
 		slog.Error("Error creating player", "error", err)
 		return db.Player{}, err
@@ -55,6 +56,15 @@ func (s *PlayerService) Get(id int) (db.Player, error) {
 	return player, nil
 }
 
+func (s *PlayerService) SetStatus(id int, status db.PlayerStatus) (db.Player, error) {
+	player, err := s.Get(id)
+	if err != nil {
+		return db.Player{}, err
+	}
+	player.Status = status
+	return s.Update(player)
+}
+
 func (s *PlayerService) Delete(id int) error {
 	err := s.db.Player.Delete(id)
 	if err != nil {
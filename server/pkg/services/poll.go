@@ -0,0 +1,162 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type PollService struct {
+	db db.Db
+}
+
+func NewPollService(db db.Db) PollService {
+	return PollService{
+		db: db,
+	}
+}
+
+func PlayerVoter(playerId int) string {
+	return fmt.Sprintf("player:%d", playerId)
+}
+
+func SpectatorVoter(key string) string {
+	return fmt.Sprintf("spectator:%s", key)
+}
+
+// Recipients are the stream ids a poll goes out to
+func PollRecipients(poll db.Poll) []int {
+	switch poll.Audience {
+	case db.PollAudiencePlayers:
+		return []int{stream.AllPlayersId}
+	case db.PollAudienceSpectators:
+		return []int{stream.AllSpectatorsId}
+	}
+	return []int{stream.AllPlayersId, stream.AllSpectatorsId}
+}
+
+func (s *PollService) Create(input db.CreatePollPayload) (db.Poll, error) {
+	poll, err := s.db.Poll.Create(input)
+	if err != nil {
+		slog.Error("Error creating poll", "error", err)
+		return db.Poll{}, err
+	}
+	poll.Tally = make([]int, len(poll.Options))
+	return poll, nil
+}
+
+// GetAll returns every poll with its votes counted, for the admin and the session recap
+func (s *PollService) GetAll() ([]db.Poll, error) {
+	polls, err := s.db.Poll.GetAll()
+	if err != nil {
+		slog.Error("Error fetching polls", "error", err)
+		return []db.Poll{}, err
+	}
+	for i := range polls {
+		polls[i], err = s.withTally(polls[i])
+		if err != nil {
+			return []db.Poll{}, err
+		}
+	}
+	return polls, nil
+}
+
+// GetAllOpenFor returns the open polls a participant can vote in, without the running tally
+func (s *PollService) GetAllOpenFor(isSpectator bool) ([]db.Poll, error) {
+	polls, err := s.db.Poll.GetAllOpen()
+	if err != nil {
+		slog.Error("Error fetching open polls", "error", err)
+		return []db.Poll{}, err
+	}
+	open := make([]db.Poll, 0, len(polls))
+	for _, poll := range polls {
+		if canVote(poll, isSpectator) {
+			poll.Tally = nil
+			open = append(open, poll)
+		}
+	}
+	return open, nil
+}
+
+// Vote records a participant's one vote and returns the poll with the new tally for the admin
+func (s *PollService) Vote(pollId int, voter string, isSpectator bool, option int) (db.Poll, error) {
+	poll, err := s.db.Poll.Get(pollId)
+	if err != nil {
+		slog.Error("Error getting poll to vote in", "error", err, "pollId", pollId)
+		return db.Poll{}, err
+	}
+	if poll.Closed {
+		return db.Poll{}, fmt.Errorf("poll is closed")
+	}
+	if !canVote(poll, isSpectator) {
+		return db.Poll{}, fmt.Errorf("not allowed to vote in this poll")
+	}
+	if option < 0 || option >= len(poll.Options) {
+		return db.Poll{}, fmt.Errorf("invalid option %d", option)
+	}
+
+	// the unique (pollId, voter) constraint is what keeps it to one vote, even when two come in at once
+	_, err = s.db.Poll.CreateVote(db.PollVote{
+		PollId: pollId,
+		Voter:  voter,
+		Option: option,
+	})
+	if db.IsUniqueViolation(err) {
+		return db.Poll{}, fmt.Errorf("already voted")
+	}
+	if err != nil {
+		slog.Error("Error saving vote", "error", err, "pollId", pollId)
+		return db.Poll{}, err
+	}
+	return s.withTally(poll)
+}
+
+// Close stops voting and stores the final tally with the poll
+func (s *PollService) Close(pollId int) (db.Poll, error) {
+	poll, err := s.db.Poll.Get(pollId)
+	if err != nil {
+		slog.Error("Error getting poll to close", "error", err, "pollId", pollId)
+		return db.Poll{}, err
+	}
+	if poll.Closed {
+		return poll, nil
+	}
+	poll, err = s.withTally(poll)
+	if err != nil {
+		return db.Poll{}, err
+	}
+	poll.Closed = true
+	poll, err = s.db.Poll.Update(poll)
+	if err != nil {
+		slog.Error("Error closing poll", "error", err, "pollId", pollId)
+		return db.Poll{}, err
+	}
+	return poll, nil
+}
+
+func (s *PollService) withTally(poll db.Poll) (db.Poll, error) {
+	if poll.Closed && len(poll.Tally) == len(poll.Options) {
+		return poll, nil
+	}
+	votes, err := s.db.Poll.GetVotes(poll.Id)
+	if err != nil {
+		slog.Error("Error getting poll votes", "error", err, "pollId", poll.Id)
+		return db.Poll{}, err
+	}
+	poll.Tally = make([]int, len(poll.Options))
+	for _, vote := range votes {
+		if vote.Option >= 0 && vote.Option < len(poll.Tally) {
+			poll.Tally[vote.Option]++
+		}
+	}
+	return poll, nil
+}
+
+func canVote(poll db.Poll, isSpectator bool) bool {
+	if isSpectator {
+		return poll.Audience != db.PollAudiencePlayers
+	}
+	return poll.Audience != db.PollAudienceSpectators
+}
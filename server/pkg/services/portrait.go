@@ -0,0 +1,212 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+const (
+	MaxPortraitBytes = 5 << 20
+	// a small file can still claim to be huge, and decoding allocates all of it
+	maxPortraitPixels = 4096 * 4096
+	thumbnailMaxSize  = 256
+	thumbnailPrefix   = "thumb-"
+	portraitUrlPrefix = "/portraits"
+)
+
+var allowedPortraitTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+}
+
+type PortraitService struct {
+	db  db.Db
+	dir string
+}
+
+func NewPortraitService(db db.Db, dir string) PortraitService {
+	ensureDir(dir)
+	return PortraitService{
+		db:  db,
+		dir: dir,
+	}
+}
+
+// Upload validates and stores an image with a generated thumbnail, then points the character at it.
+func (s *PortraitService) Upload(characterId int, data []byte) (db.CharacterWithActions, error) {
+	_, ext, err := detectUploadType(data, MaxPortraitBytes, allowedPortraitTypes)
+	if err != nil {
+		return db.CharacterWithActions{}, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Error decoding portrait size", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, fmt.Errorf("portrait is not a valid image: %w", err)
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPortraitPixels {
+		return db.CharacterWithActions{}, fmt.Errorf("portrait is %dx%d, it can't be bigger than 4096x4096", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		slog.Error("Error decoding portrait", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, fmt.Errorf("portrait is not a valid image: %w", err)
+	}
+
+	character, err := s.db.Character.Get(characterId)
+	if err != nil {
+		slog.Error("Error getting character for portrait", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+
+	name := randomFileName(fmt.Sprint(characterId), ext)
+	thumbName := thumbnailPrefix + name[:len(name)-len(ext)] + ".png"
+
+	err = os.WriteFile(filepath.Join(s.dir, name), data, 0o644)
+	if err != nil {
+		slog.Error("Error writing portrait", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+	var thumb bytes.Buffer
+	err = png.Encode(&thumb, thumbnail(img, thumbnailMaxSize))
+	if err != nil {
+		slog.Error("Error encoding portrait thumbnail", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+	err = os.WriteFile(filepath.Join(s.dir, thumbName), thumb.Bytes(), 0o644)
+	if err != nil {
+		slog.Error("Error writing portrait thumbnail", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+
+	previous := []string{character.Portrait, character.PortraitThumbnail}
+	character.Portrait = portraitUrl(characterId, name)
+	character.PortraitThumbnail = portraitUrl(characterId, thumbName)
+	character, err = s.db.Character.Update(character)
+	if err != nil {
+		slog.Error("Error updating character portrait", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+	for _, url := range previous {
+		if url != "" {
+			os.Remove(filepath.Join(s.dir, filepath.Base(url)))
+		}
+	}
+
+	actions, err := s.db.Action.GetAll(characterId)
+	if err != nil {
+		slog.Error("Error fetching actions after updating portrait", "error", err, "characterId", characterId)
+		return db.CharacterWithActions{}, err
+	}
+	return db.CharacterWithActions{
+		Character: character,
+		Actions:   actions,
+	}, nil
+}
+
+// Path returns the file on disk for a portrait of the character if the player is allowed to see it.
+// Uses the same rules as the redacted character players receive over the stream,
+// and with the table view on, the other players get the public portraits the table shows them.
+func (s *PortraitService) Path(characterId int, file string, playerId int, isAdmin bool, tableView bool) (string, error) {
+	character, err := s.db.Character.Get(characterId)
+	if err != nil {
+		slog.Error("Error getting character for portrait", "error", err, "characterId", characterId)
+		return "", err
+	}
+	if !isAdmin {
+		if character.PlayerId == nil || *character.PlayerId != playerId {
+			if tableView {
+				return s.PublicPath(characterId, file)
+			}
+			return "", fmt.Errorf("character not assigned to player")
+		}
+		fields, err := s.db.Character.GetRevealedFields(characterId)
+		if err != nil {
+			slog.Error("error getting revealed fields for character", "error", err, "characterId", characterId)
+			return "", err
+		}
+		redactCharacter(&character, fields)
+	}
+	return s.path(character, file)
+}
+
+// PublicPath returns the file on disk for a portrait the audience is allowed to see,
+// one that's both revealed and public like in the spectator view.
+func (s *PortraitService) PublicPath(characterId int, file string) (string, error) {
+	character, err := s.db.Character.Get(characterId)
+	if err != nil {
+		slog.Error("Error getting character for portrait", "error", err, "characterId", characterId)
+		return "", err
+	}
+	fields, _, err := publicFields(s.db, character)
+	if err != nil {
+		return "", err
+	}
+	redactCharacter(&character, fields)
+	return s.path(character, file)
+}
+
+// path only finds files the character still points at, after redacting that's the ones the viewer can see
+func (s *PortraitService) path(character db.Character, file string) (string, error) {
+	for _, url := range []string{character.Portrait, character.PortraitThumbnail} {
+		if url != "" && url == portraitUrl(character.Id, file) {
+			return filepath.Join(s.dir, filepath.Base(url)), nil
+		}
+	}
+	return "", fmt.Errorf("portrait not found")
+}
+
+func portraitUrl(characterId int, file string) string {
+	return fmt.Sprintf("%s/%d/%s", portraitUrlPrefix, characterId, filepath.Base(file))
+}
+
+// thumbnail scales the image down so neither side is larger than maxSize,
+// averaging the source pixels that land in each thumbnail pixel.
+func thumbnail(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+	scale := float64(maxSize) / float64(max(width, height))
+	dstWidth := max(1, int(float64(width)*scale))
+	dstHeight := max(1, int(float64(height)*scale))
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*height/dstHeight)
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := max(x0+1, bounds.Min.X+(x+1)*width/dstWidth)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+// the longest chain of rules a single reveal can set off, a backstop for the cycle check
+const maxRuleChain = 32
+
+// RuleService fires reveal rules after an action or a character field is revealed.
+// Effects are streamed to the admin and players here since delayed effects
+// happen long after the request that triggered them is gone.
+type RuleService struct {
+	db         db.Db
+	stream     stream.StreamingServer
+	templates  TemplateService
+	actions    ActionService
+	characters CharacterService
+	spectators SpectatorService
+}
+
+func NewRuleService(db db.Db, stream stream.StreamingServer, spectators SpectatorService) *RuleService {
+	rules := &RuleService{
+		db:         db,
+		stream:     stream,
+		templates:  NewTemplateService(db),
+		spectators: spectators,
+	}
+	rules.actions = NewActionService(db, rules)
+	rules.characters = NewCharacterService(db, stream, rules)
+	return rules
+}
+
+func (s *RuleService) GetAll() ([]db.RevealRule, error) {
+	rules, err := s.db.Rule.GetAll()
+	if err != nil {
+		slog.Error("Error fetching reveal rules", "error", err)
+		return []db.RevealRule{}, err
+	}
+	return rules, nil
+}
+
+func (s *RuleService) Create(input db.CreateRevealRulePayload) (db.RevealRule, error) {
+	trigger, err := ruleTrigger(input)
+	if err != nil {
+		return db.RevealRule{}, err
+	}
+	effect, err := ruleEffect(input)
+	if err != nil {
+		return db.RevealRule{}, err
+	}
+	if input.DelaySeconds < 0 {
+		return db.RevealRule{}, fmt.Errorf("delay can't be negative")
+	}
+
+	rules, err := s.GetAll()
+	if err != nil {
+		return db.RevealRule{}, err
+	}
+	if trigger == effect || reachable(rules, effect, trigger) {
+		return db.RevealRule{}, fmt.Errorf("rule would create a cycle")
+	}
+
+	rule, err := s.db.Rule.Create(input)
+	if err != nil {
+		slog.Error("Error creating reveal rule", "error", err)
+		return db.RevealRule{}, err
+	}
+	return rule, nil
+}
+
+func (s *RuleService) Delete(id int) error {
+	err := s.db.Rule.Delete(id)
+	if err != nil {
+		slog.Error("Error deleting reveal rule", "error", err)
+		return err
+	}
+	return nil
+}
+
+// ruleChain is the set of triggers already fired by one reveal, so a cycle
+// that sneaks past Create still stops.
+type ruleChain map[string]bool
+
+func (s *RuleService) actionRevealed(actionId int, chain ruleChain) {
+	s.fire(actionNode(actionId), chain)
+}
+
+func (s *RuleService) fieldsRevealed(characterId int, fields []string, chain ruleChain) {
+	for _, field := range fields {
+		s.fire(fieldNode(characterId, field), chain)
+	}
+}
+
+func (s *RuleService) fire(trigger string, chain ruleChain) {
+	if chain == nil {
+		chain = ruleChain{}
+	}
+	if chain[trigger] || len(chain) >= maxRuleChain {
+		slog.Error("reveal rules stopped to avoid a cycle", "trigger", trigger)
+		return
+	}
+	chain[trigger] = true
+
+	rules, err := s.GetAll()
+	if err != nil {
+		return
+	}
+	for _, rule := range rules {
+		if t, _ := ruleTrigger(rule.CreateRevealRulePayload); t != trigger {
+			continue
+		}
+		slog.Info("firing reveal rule", "ruleId", rule.Id, "trigger", trigger, "delaySeconds", rule.DelaySeconds)
+		if rule.DelaySeconds == 0 {
+			s.apply(rule, chain)
+			continue
+		}
+		// copy the chain, the delayed effect can't share a map with other goroutines
+		delayed := make(ruleChain, len(chain))
+		for node := range chain {
+			delayed[node] = true
+		}
+		time.AfterFunc(time.Duration(rule.DelaySeconds)*time.Second, func() {
+			s.apply(rule, delayed)
+		})
+	}
+}
+
+func (s *RuleService) apply(rule db.RevealRule, chain ruleChain) {
+	if rule.EffectActionId != nil {
+		playerId, action, err := s.actions.reveal(*rule.EffectActionId, chain)
+		if err != nil || playerId == 0 {
+			return
+		}
+		rendered, err := s.templates.RenderAction(action)
+		if err != nil {
+			return
+		}
+		s.stream.SendPlayerActionMessage(playerId, action, rendered)
+		s.spectators.Sync(action.CharacterId)
+		return
+	}
+
+	fields, err := s.db.Character.GetRevealedFields(*rule.EffectCharacterId)
+	if err != nil {
+		slog.Error("error getting revealed fields for rule", "error", err, "ruleId", rule.Id)
+		return
+	}
+	if !setRevealedField(&fields, rule.EffectField, true) {
+		slog.Error("reveal rule has an unknown field", "ruleId", rule.Id, "field", rule.EffectField)
+		return
+	}
+	character, fields, err := s.characters.updateRevealedFields(fields, chain)
+	if err != nil {
+		return
+	}
+	redacted, err := s.characters.Redact(character)
+	if err != nil {
+		return
+	}
+	s.stream.SendAdminCharacterMessageWithFields(character, fields)
+	s.stream.SendPlayerCharacterMessage(redacted)
+	s.spectators.Sync(character.Id)
+}
+
+func actionNode(actionId int) string {
+	return fmt.Sprintf("action:%d", actionId)
+}
+
+func fieldNode(characterId int, field string) string {
+	return fmt.Sprintf("field:%d:%s", characterId, field)
+}
+
+func ruleTrigger(rule db.CreateRevealRulePayload) (string, error) {
+	return ruleNode(rule.TriggerActionId, rule.TriggerCharacterId, rule.TriggerField)
+}
+
+func ruleEffect(rule db.CreateRevealRulePayload) (string, error) {
+	return ruleNode(rule.EffectActionId, rule.EffectCharacterId, rule.EffectField)
+}
+
+func ruleNode(actionId *int, characterId *int, field string) (string, error) {
+	if actionId != nil && characterId == nil && field == "" {
+		return actionNode(*actionId), nil
+	}
+	if actionId == nil && characterId != nil {
+		if !setRevealedField(&db.CharacterReveleadFields{}, field, true) {
+			return "", fmt.Errorf("unknown character field %q", field)
+		}
+		return fieldNode(*characterId, field), nil
+	}
+	return "", fmt.Errorf("a rule needs either an action or a character and field")
+}
+
+// reachable reports whether the rules lead from one trigger to another
+func reachable(rules []db.RevealRule, from string, to string) bool {
+	seen := map[string]bool{}
+	next := []string{from}
+	for len(next) > 0 {
+		node := next[len(next)-1]
+		next = next[:len(next)-1]
+		if node == to {
+			return true
+		}
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+		for _, rule := range rules {
+			trigger, err := ruleTrigger(rule.CreateRevealRulePayload)
+			if err != nil || trigger != node {
+				continue
+			}
+			effect, err := ruleEffect(rule.CreateRevealRulePayload)
+			if err == nil {
+				next = append(next, effect)
+			}
+		}
+	}
+	return false
+}
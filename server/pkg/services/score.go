@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type ScoreService struct {
+	db db.Db
+}
+
+func NewScoreService(db db.Db) ScoreService {
+	return ScoreService{
+		db: db,
+	}
+}
+
+func (s *ScoreService) Award(input db.CreateScoreEntryPayload) (db.ScoreEntry, error) {
+	_, err := s.db.Player.Get(input.PlayerId)
+	if err != nil {
+		slog.Error("Error getting player to award", "error", err, "playerId", input.PlayerId)
+		return db.ScoreEntry{}, err
+	}
+	if input.ActionId != nil {
+		action, err := s.db.Action.Get(*input.ActionId)
+		if err != nil {
+			slog.Error("Error getting action for award", "error", err, "actionId", *input.ActionId)
+			return db.ScoreEntry{}, err
+		}
+		if !action.Revealed {
+			return db.ScoreEntry{}, fmt.Errorf("can only award points for a revealed action")
+		}
+	}
+	entry, err := s.db.Score.Create(input)
+	if err != nil {
+		slog.Error("Error creating score entry", "error", err)
+		return db.ScoreEntry{}, err
+	}
+	return entry, nil
+}
+
+// GetScoreboard totals up the current session for every player, highest first
+func (s *ScoreService) GetScoreboard() (stream.ScoreboardData, error) {
+	entries, err := s.db.Score.GetAllCurrent()
+	if err != nil {
+		slog.Error("Error fetching score entries", "error", err)
+		return stream.ScoreboardData{}, err
+	}
+	players, err := s.db.Player.GetAll()
+	if err != nil {
+		slog.Error("Error fetching players", "error", err)
+		return stream.ScoreboardData{}, err
+	}
+
+	points := make(map[int]int)
+	for _, entry := range entries {
+		points[entry.PlayerId] += entry.Points
+	}
+	totals := make([]stream.PlayerScore, 0, len(players))
+	for _, player := range players {
+		if player.Id == stream.AdminPlayerId {
+			continue
+		}
+		totals = append(totals, stream.PlayerScore{
+			PlayerId: player.Id,
+			Name:     player.Name,
+			Points:   points[player.Id],
+		})
+	}
+	sort.SliceStable(totals, func(i, j int) bool {
+		return totals[i].Points > totals[j].Points
+	})
+
+	return stream.ScoreboardData{
+		Totals:  totals,
+		Entries: entries,
+	}, nil
+}
+
+func (s *ScoreService) Reset() error {
+	err := s.db.Score.Archive()
+	if err != nil {
+		slog.Error("Error resetting scores", "error", err)
+		return err
+	}
+	return nil
+}
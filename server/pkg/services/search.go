@@ -0,0 +1,220 @@
+package services
+
+import (
+	"html"
+	"log/slog"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+const (
+	snippetRadius   = 60
+	highlightOpen   = "<mark>"
+	highlightClose  = "</mark>"
+	defaultMaxHits  = 25
+	resultCharacter = "character"
+	resultAction    = "action"
+)
+
+// how much a hit in each field counts towards the rank of a result
+var fieldWeights = map[string]float64{
+	"name":        3,
+	"appearance":  2,
+	"description": 1.5,
+	"content":     1,
+}
+
+type SearchResult struct {
+	Type        string  `json:"type"`
+	CharacterId int     `json:"characterId"`
+	ActionId    int     `json:"actionId,omitempty"`
+	Field       string  `json:"field"`
+	Score       float64 `json:"score"`
+	Snippet     string  `json:"snippet"`
+}
+
+type SearchService struct {
+	db db.Db
+}
+
+func NewSearchService(db db.Db) SearchService {
+	return SearchService{
+		db: db,
+	}
+}
+
+func (s *SearchService) Search(query string, limit int) ([]SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, nil
+	}
+	if limit <= 0 {
+		limit = defaultMaxHits
+	}
+
+	characters, err := s.db.Character.Search(terms)
+	if err != nil {
+		slog.Error("Error searching characters", "error", err, "query", query)
+		return []SearchResult{}, err
+	}
+	actions, err := s.db.Action.Search(terms)
+	if err != nil {
+		slog.Error("Error searching actions", "error", err, "query", query)
+		return []SearchResult{}, err
+	}
+
+	results := make([]SearchResult, 0, len(characters)+len(actions))
+	for _, character := range characters {
+		result := SearchResult{Type: resultCharacter, CharacterId: character.Id}
+		fields := []struct{ name, text string }{
+			{"name", character.Name},
+			{"appearance", character.Appearance},
+			{"description", character.Description},
+		}
+		for _, field := range fields {
+			score := scoreText(field.text, terms) * fieldWeights[field.name]
+			if score > result.Score {
+				result.Score = score
+				result.Field = field.name
+				result.Snippet = highlightSnippet(field.text, terms)
+			}
+		}
+		if result.Score > 0 {
+			results = append(results, result)
+		}
+	}
+	for _, action := range actions {
+		score := scoreText(action.Content, terms) * fieldWeights["content"]
+		if score == 0 {
+			continue
+		}
+		results = append(results, SearchResult{
+			Type:        resultAction,
+			CharacterId: action.CharacterId,
+			ActionId:    action.Id,
+			Field:       "content",
+			Score:       score,
+			Snippet:     highlightSnippet(action.Content, terms),
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// searchTerms splits a query into lowercase words, dropping anything that isn't
+// a letter or digit so the terms are safe to put in a database filter.
+func searchTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	seen := make(map[string]bool, len(words))
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// scoreText rewards matching many distinct terms more than matching one term many times.
+func scoreText(text string, terms []string) float64 {
+	lower := strings.ToLower(text)
+	score := 0.0
+	for _, term := range terms {
+		count := strings.Count(lower, term)
+		if count == 0 {
+			continue
+		}
+		score += 1 + 0.25*float64(count-1)
+		if hasWholeWord(lower, term) {
+			score += 0.5
+		}
+	}
+	return score
+}
+
+func hasWholeWord(text string, term string) bool {
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if word == term {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet cuts a window of text around the first match and wraps every
+// match inside it in <mark> tags. The text is escaped, the marks are the only markup in it.
+func highlightSnippet(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		// lowercasing changed byte offsets, fall back to the raw text
+		lower = text
+	}
+
+	first := -1
+	for _, term := range terms {
+		if i := strings.Index(lower, term); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := max(0, first-snippetRadius)
+	end := min(len(text), first+snippetRadius)
+	for start > 0 && !isRuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !isRuneStart(text[end]) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	i := start
+	plain := start
+	for i < end {
+		matched := ""
+		for _, term := range terms {
+			if strings.HasPrefix(lower[i:], term) && len(term) > len(matched) {
+				matched = term
+			}
+		}
+		if matched == "" {
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(text[plain:i]))
+		b.WriteString(highlightOpen)
+		b.WriteString(html.EscapeString(text[i : i+len(matched)]))
+		b.WriteString(highlightClose)
+		i += len(matched)
+		plain = i
+	}
+	b.WriteString(html.EscapeString(text[plain:i]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+// Player and spectator tokens are signed with the same secrets,
+// the type keeps one from being passed off as the other.
+const (
+	sessionTypePlayer    = "player"
+	sessionTypeSpectator = "spectator"
+)
+
+// Session is everything the session cookie says about who is logged in.
+// It's signed, so nobody can change it without the server secret.
+type Session struct {
+	Type string `json:"typ"`
+	// the row in the sessions table, gone once the session is revoked
+	SessionId string `json:"sid"`
+	// player id or admin stream id
+	Id        int   `json:"id"`
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// SessionService signs and checks session tokens, and keeps track of them in the sessions table so they can be revoked.
+// The first secret signs new tokens, the others are only accepted so secrets can be rotated
+// without logging everyone out.
+type SessionService struct {
+	db      db.Db
+	secrets [][]byte
+	ttl     time.Duration
+}
+
+// There has to be at least one secret, the config makes sure of it.
+func NewSessionService(db db.Db, secrets []string, ttl time.Duration) SessionService {
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		keys = append(keys, []byte(secret))
+	}
+	return SessionService{
+		db:      db,
+		secrets: keys,
+		ttl:     ttl,
+	}
+}
+
+func (s SessionService) TTL() time.Duration {
+	return s.ttl
+}
+
+// Issue starts a new session for an id
+func (s SessionService) Issue(id int) (string, Session, error) {
+	sessionId := make([]byte, 16)
+	_, err := rand.Read(sessionId)
+	if err != nil {
+		return "", Session{}, err
+	}
+	now := time.Now()
+	session := Session{
+		Type:      sessionTypePlayer,
+		SessionId: hex.EncodeToString(sessionId),
+		Id:        id,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.ttl).Unix(),
+	}
+	_, err = s.db.Session.Create(db.Session{
+		Id:        session.SessionId,
+		PlayerId:  id,
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		slog.Error("Error creating session", "error", err, "id", id)
+		return "", Session{}, err
+	}
+	// good a time as any to clean up
+	err = s.db.Session.DeleteExpired(now.Unix())
+	if err != nil {
+		slog.Error("Error deleting expired sessions", "error", err)
+	}
+	return s.encode(session)
+}
+
+// Refresh gives a session a new token and expiry, it stays the same session so it can still be revoked
+func (s SessionService) Refresh(session Session) (string, Session, error) {
+	now := time.Now()
+	session.IssuedAt = now.Unix()
+	session.ExpiresAt = now.Add(s.ttl).Unix()
+	err := s.db.Session.SetExpiresAt(session.SessionId, session.ExpiresAt)
+	if err != nil {
+		slog.Error("Error refreshing session", "error", err, "id", session.Id)
+		return "", Session{}, err
+	}
+	return s.encode(session)
+}
+
+// Revoke logs out one device
+func (s SessionService) Revoke(sessionId string) error {
+	err := s.db.Session.Delete(sessionId)
+	if err != nil {
+		slog.Error("Error revoking session", "error", err)
+	}
+	return err
+}
+
+// RevokeAll logs out every device of a player or admin
+func (s SessionService) RevokeAll(id int) error {
+	err := s.db.Session.DeleteAllForPlayer(id)
+	if err != nil {
+		slog.Error("Error revoking sessions", "error", err, "id", id)
+	}
+	return err
+}
+
+// RevokeAllPlayers logs out every player, the admins stay logged in
+func (s SessionService) RevokeAllPlayers() error {
+	err := s.db.Session.DeleteAllPlayers()
+	if err != nil {
+		slog.Error("Error revoking all player sessions", "error", err)
+	}
+	return err
+}
+
+func (s SessionService) encode(session Session) (string, Session, error) {
+	token, err := s.seal(session)
+	if err != nil {
+		return "", Session{}, err
+	}
+	return token, session, nil
+}
+
+// Parse checks the signature and expiry of a token, and that the session hasn't been revoked.
+// refresh is true when the token should be swapped for a new one,
+// because it was signed with an old secret or it's past the middle of its life.
+func (s SessionService) Parse(token string) (session Session, refresh bool, err error) {
+	refresh, err = s.open(token, sessionTypePlayer, &session)
+	if err != nil {
+		return Session{}, false, err
+	}
+
+	now := time.Now().Unix()
+	if now >= session.ExpiresAt {
+		return Session{}, false, fmt.Errorf("session expired")
+	}
+	_, err = s.db.Session.Get(session.SessionId)
+	if err != nil {
+		return Session{}, false, fmt.Errorf("session revoked")
+	}
+	if now-session.IssuedAt > (session.ExpiresAt-session.IssuedAt)/2 {
+		refresh = true
+	}
+	return session, refresh, nil
+}
+
+// SpectatorSession is the audience's version of a session. Spectators aren't in the db,
+// so there's nothing to revoke, but the signature still keeps them from picking their own key.
+type SpectatorSession struct {
+	Type string `json:"typ"`
+	// random, identifies the spectator for things like voting
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// IssueSpectator signs a spectator session, it makes up a key if there isn't one yet
+func (s SessionService) IssueSpectator(key string, name string) (string, SpectatorSession, error) {
+	if key == "" {
+		random := make([]byte, 16)
+		_, err := rand.Read(random)
+		if err != nil {
+			return "", SpectatorSession{}, err
+		}
+		key = hex.EncodeToString(random)
+	}
+	session := SpectatorSession{
+		Type:      sessionTypeSpectator,
+		Key:       key,
+		Name:      name,
+		ExpiresAt: time.Now().Add(s.ttl).Unix(),
+	}
+	token, err := s.seal(session)
+	if err != nil {
+		return "", SpectatorSession{}, err
+	}
+	return token, session, nil
+}
+
+func (s SessionService) ParseSpectator(token string) (SpectatorSession, error) {
+	var session SpectatorSession
+	_, err := s.open(token, sessionTypeSpectator, &session)
+	if err != nil {
+		return SpectatorSession{}, err
+	}
+	if time.Now().Unix() >= session.ExpiresAt {
+		return SpectatorSession{}, fmt.Errorf("session expired")
+	}
+	return session, nil
+}
+
+// seal signs a payload with the newest secret
+func (s SessionService) seal(payload any) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + "." + s.sign(s.secrets[0], encoded), nil
+}
+
+// open checks the signature and type of a token and decodes its payload,
+// oldSecret is true when it was signed with a secret that's being rotated out
+func (s SessionService) open(token string, kind string, payload any) (oldSecret bool, err error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false, fmt.Errorf("malformed session token")
+	}
+
+	valid := false
+	for i, secret := range s.secrets {
+		if hmac.Equal([]byte(signature), []byte(s.sign(secret, encoded))) {
+			valid = true
+			oldSecret = i > 0
+			break
+		}
+	}
+	if !valid {
+		return false, fmt.Errorf("invalid session signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false, err
+	}
+	var header struct {
+		Type string `json:"typ"`
+	}
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return false, err
+	}
+	if header.Type != kind {
+		return false, fmt.Errorf("not a %s session token", kind)
+	}
+	return oldSecret, json.Unmarshal(data, payload)
+}
+
+func (s SessionService) sign(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
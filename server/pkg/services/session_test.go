@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+// fakeSessions stands in for the sessions table, it answers the few postgrest requests SessionService makes
+type fakeSessions struct {
+	mu   sync.Mutex
+	rows map[string]db.Session
+}
+
+func (f *fakeSessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	id := strings.TrimPrefix(r.URL.Query().Get("id"), "eq.")
+
+	switch r.Method {
+	case http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		var session db.Session
+		json.Unmarshal(body, &session)
+		f.rows[session.Id] = session
+		w.WriteHeader(201)
+		w.Write(body)
+	case http.MethodGet:
+		session, ok := f.rows[id]
+		if !ok {
+			w.WriteHeader(406)
+			w.Write([]byte(`{"code":"PGRST116","message":"JSON object requested, multiple (or no) rows returned"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(session)
+	case http.MethodPatch:
+		var update struct {
+			ExpiresAt int64 `json:"expiresAt"`
+		}
+		json.NewDecoder(r.Body).Decode(&update)
+		if session, ok := f.rows[id]; ok {
+			session.ExpiresAt = update.ExpiresAt
+			f.rows[id] = session
+		}
+		w.WriteHeader(204)
+	case http.MethodDelete:
+		if id != "" {
+			delete(f.rows, id)
+		}
+		if before := strings.TrimPrefix(r.URL.Query().Get("expiresAt"), "lt."); before != "" {
+			now, _ := strconv.ParseInt(before, 10, 64)
+			for key, session := range f.rows {
+				if session.ExpiresAt < now {
+					delete(f.rows, key)
+				}
+			}
+		}
+		w.WriteHeader(204)
+	}
+}
+
+func newTestSessionDb(t *testing.T) db.Db {
+	server := httptest.NewServer(&fakeSessions{rows: make(map[string]db.Session)})
+	t.Cleanup(server.Close)
+	return db.New(server.URL, "test-key")
+}
+
+func TestSessionIssueAndParse(t *testing.T) {
+	sessions := NewSessionService(newTestSessionDb(t), []string{"secret"}, time.Hour)
+
+	token, issued, err := sessions.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, refresh, err := sessions.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Id != 7 || session.SessionId != issued.SessionId {
+		t.Fatalf("expected the issued session back, got %+v", session)
+	}
+	if refresh {
+		t.Fatal("a fresh token shouldn't need refreshing")
+	}
+}
+
+func TestSessionTamperedSignature(t *testing.T) {
+	sessions := NewSessionService(newTestSessionDb(t), []string{"secret"}, time.Hour)
+	token, issued, err := sessions.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// someone else's id with the original signature
+	issued.Id = 8
+	data, _ := json.Marshal(issued)
+	_, signature, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString(data) + "." + signature
+	if _, _, err := sessions.Parse(forged); err == nil {
+		t.Fatal("expected a changed payload to be rejected")
+	}
+
+	// signed with a secret the server doesn't have
+	other := NewSessionService(newTestSessionDb(t), []string{"someone else's"}, time.Hour)
+	foreign, _, err := other.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sessions.Parse(foreign); err == nil {
+		t.Fatal("expected a token signed with another secret to be rejected")
+	}
+}
+
+func TestSessionExpired(t *testing.T) {
+	sessions := NewSessionService(newTestSessionDb(t), []string{"secret"}, -time.Minute)
+	token, _, err := sessions.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sessions.Parse(token); err == nil {
+		t.Fatal("expected an expired token to be rejected")
+	}
+}
+
+func TestSessionSecretRotation(t *testing.T) {
+	database := newTestSessionDb(t)
+	before := NewSessionService(database, []string{"old"}, time.Hour)
+	rotating := NewSessionService(database, []string{"new", "old"}, time.Hour)
+	after := NewSessionService(database, []string{"new"}, time.Hour)
+
+	token, _, err := before.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, refresh, err := rotating.Parse(token)
+	if err != nil {
+		t.Fatalf("expected the old secret to still work while rotating: %v", err)
+	}
+	if !refresh {
+		t.Fatal("expected a token signed with the old secret to be refreshed")
+	}
+
+	refreshed, _, err := rotating.Refresh(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := after.Parse(refreshed); err != nil {
+		t.Fatalf("expected the refreshed token to work once the old secret is gone: %v", err)
+	}
+	if _, _, err := after.Parse(token); err == nil {
+		t.Fatal("expected the old token to stop working once the old secret is gone")
+	}
+}
+
+func TestSessionRevoked(t *testing.T) {
+	sessions := NewSessionService(newTestSessionDb(t), []string{"secret"}, time.Hour)
+	token, issued, err := sessions.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sessions.Revoke(issued.SessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sessions.Parse(token); err == nil {
+		t.Fatal("expected a revoked session to be rejected")
+	}
+}
+
+func TestSessionTypesDontMix(t *testing.T) {
+	sessions := NewSessionService(newTestSessionDb(t), []string{"secret"}, time.Hour)
+
+	player, _, err := sessions.Issue(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.ParseSpectator(player); err == nil {
+		t.Fatal("expected a player token to be rejected as a spectator")
+	}
+
+	spectator, _, err := sessions.IssueSpectator("", "Audience")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := sessions.Parse(spectator); err == nil {
+		t.Fatal("expected a spectator token to be rejected as a player")
+	}
+	if _, err := sessions.ParseSpectator(spectator); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"log/slog"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+// SpectatorService builds the views of characters for people who aren't voicing them:
+// the audience, and when the table view is on, the other players.
+// Both only get fields (and for the audience, actions) that are revealed and marked public by the GM.
+type SpectatorService struct {
+	db        db.Db
+	stream    stream.StreamingServer
+	templates TemplateService
+	tableView bool
+}
+
+func NewSpectatorService(db db.Db, stream stream.StreamingServer, tableView bool) SpectatorService {
+	return SpectatorService{
+		db:        db,
+		stream:    stream,
+		templates: NewTemplateService(db),
+		tableView: tableView,
+	}
+}
+
+func (s *SpectatorService) TableViewEnabled() bool {
+	return s.tableView
+}
+
+func (s *SpectatorService) GetAllPublicFields() ([]db.CharacterReveleadFields, error) {
+	characters, err := s.db.Character.GetAll()
+	if err != nil {
+		slog.Error("Error fetching characters", "error", err)
+		return []db.CharacterReveleadFields{}, err
+	}
+	publicFields := make([]db.CharacterReveleadFields, len(characters))
+	for i, character := range characters {
+		publicFields[i], err = s.db.Character.GetPublicFields(character.Id)
+		if err != nil {
+			slog.Error("error getting public fields for character", "error", err, "characterId", character.Id)
+			return []db.CharacterReveleadFields{}, err
+		}
+	}
+	return publicFields, nil
+}
+
+func (s *SpectatorService) UpdatePublicFields(input db.CharacterReveleadFields) (db.CharacterReveleadFields, error) {
+	fields, err := s.db.Character.UpdatePublicFields(input)
+	if err != nil {
+		slog.Error("error updating public fields", "error", err, "characterId", input.CharacterId)
+		return db.CharacterReveleadFields{}, err
+	}
+	return fields, nil
+}
+
+// GetAllPublic returns every character that has something the audience can see
+func (s *SpectatorService) GetAllPublic() ([]db.CharacterWithActions, error) {
+	characters, err := s.db.Character.GetAll()
+	if err != nil {
+		slog.Error("Error fetching characters", "error", err)
+		return []db.CharacterWithActions{}, err
+	}
+	public := make([]db.CharacterWithActions, 0)
+	for _, character := range characters {
+		view, visible, err := s.publicView(character)
+		if err != nil {
+			return []db.CharacterWithActions{}, err
+		}
+		if visible {
+			public = append(public, view)
+		}
+	}
+	return public, nil
+}
+
+// GetTable returns the public fields of every character for the table view, without any actions
+func (s *SpectatorService) GetTable() ([]db.Character, error) {
+	characters, err := s.db.Character.GetAll()
+	if err != nil {
+		slog.Error("Error fetching characters", "error", err)
+		return []db.Character{}, err
+	}
+	table := make([]db.Character, 0)
+	for _, character := range characters {
+		visible, anyVisible, err := publicFields(s.db, character)
+		if err != nil {
+			return []db.Character{}, err
+		}
+		if anyVisible {
+			redactCharacter(&character, visible)
+			table = append(table, character)
+		}
+	}
+	return table, nil
+}
+
+// Sync sends spectators, and players if the table view is on, the current public view
+// of a character after anything about it changed.
+// A character that no longer exists or has nothing public is deleted from their view.
+func (s *SpectatorService) Sync(characterId int) {
+	character, err := s.db.Character.Get(characterId)
+	if err != nil || character.Id == 0 {
+		s.stream.SendSpectatorDeleteCharacterMessage(characterId)
+		if s.tableView {
+			s.stream.SendTableDeleteCharacterMessage(characterId)
+		}
+		return
+	}
+
+	view, visible, err := s.publicView(character)
+	if err != nil {
+		return
+	}
+	if visible {
+		s.stream.SendSpectatorCharacterMessage(view)
+	} else {
+		s.stream.SendSpectatorDeleteCharacterMessage(characterId)
+	}
+
+	if !s.tableView {
+		return
+	}
+	visibleFields, anyVisible, err := publicFields(s.db, character)
+	if err != nil {
+		return
+	}
+	if !anyVisible {
+		s.stream.SendTableDeleteCharacterMessage(characterId)
+		return
+	}
+	redactCharacter(&character, visibleFields)
+	s.stream.SendTableCharacterMessage(character)
+}
+
+func (s *SpectatorService) publicView(character db.Character) (db.CharacterWithActions, bool, error) {
+	visibleFields, anyVisible, err := publicFields(s.db, character)
+	if err != nil {
+		return db.CharacterWithActions{}, false, err
+	}
+	actions, err := s.db.Action.GetAllRevealed(character.Id)
+	if err != nil {
+		slog.Error("error getting actions for character", "error", err, "characterId", character.Id)
+		return db.CharacterWithActions{}, false, err
+	}
+
+	publicActions := make([]db.Action, 0)
+	for _, action := range actions {
+		if action.Public {
+			publicActions = append(publicActions, action)
+		}
+	}
+	publicActions, err = s.templates.RenderActions(character, visibleFields, publicActions)
+	if err != nil {
+		return db.CharacterWithActions{}, false, err
+	}
+	redactCharacter(&character, visibleFields)
+
+	view := db.CharacterWithActions{
+		Character: character,
+		Actions:   publicActions,
+	}
+	return view, anyVisible || len(publicActions) > 0, nil
+}
+
+// publicFields are the fields that are both revealed and public, in the shape redactCharacter takes
+func publicFields(database db.Db, character db.Character) (db.CharacterReveleadFields, bool, error) {
+	revealed, err := database.Character.GetRevealedFields(character.Id)
+	if err != nil {
+		slog.Error("error getting revealed fields for character", "error", err, "characterId", character.Id)
+		return db.CharacterReveleadFields{}, false, err
+	}
+	public, err := database.Character.GetPublicFields(character.Id)
+	if err != nil {
+		slog.Error("error getting public fields for character", "error", err, "characterId", character.Id)
+		return db.CharacterReveleadFields{}, false, err
+	}
+
+	visibleFields := db.CharacterReveleadFields{CharacterId: character.Id}
+	anyVisible := false
+	for _, field := range revealableFields {
+		visible := isFieldRevealed(revealed, field) && isFieldRevealed(public, field)
+		setRevealedField(&visibleFields, field, visible)
+		anyVisible = anyVisible || visible
+	}
+	return visibleFields, anyVisible, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+var (
+	placeholderPattern  = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+type TemplateService struct {
+	db db.Db
+}
+
+func NewTemplateService(db db.Db) TemplateService {
+	return TemplateService{
+		db: db,
+	}
+}
+
+func (s *TemplateService) GetVariables() ([]db.Variable, error) {
+	variables, err := s.db.Variable.GetAll()
+	if err != nil {
+		slog.Error("Error fetching variables", "error", err)
+		return []db.Variable{}, err
+	}
+	return variables, nil
+}
+
+func (s *TemplateService) SetVariable(input db.Variable) (db.Variable, error) {
+	if !variableNamePattern.MatchString(input.Name) {
+		return db.Variable{}, fmt.Errorf("variable names can only use letters, numbers, _ and -")
+	}
+	variable, err := s.db.Variable.Set(input)
+	if err != nil {
+		slog.Error("Error setting variable", "error", err, "name", input.Name)
+		return db.Variable{}, err
+	}
+	return variable, nil
+}
+
+func (s *TemplateService) DeleteVariable(name string) error {
+	err := s.db.Variable.Delete(name)
+	if err != nil {
+		slog.Error("Error deleting variable", "error", err, "name", name)
+		return err
+	}
+	return nil
+}
+
+// RenderAction fills in the placeholders of an action for the player its character is assigned to.
+// The admin always gets the raw template, only send the result to players.
+func (s *TemplateService) RenderAction(action db.Action) (db.Action, error) {
+	character, err := s.db.Character.Get(action.CharacterId)
+	if err != nil {
+		slog.Error("error getting character to render action", "error", err, "characterId", action.CharacterId)
+		return db.Action{}, err
+	}
+	fields, err := s.db.Character.GetRevealedFields(action.CharacterId)
+	if err != nil {
+		slog.Error("error getting revealed fields to render action", "error", err, "characterId", action.CharacterId)
+		return db.Action{}, err
+	}
+	actions, err := s.RenderActions(character, fields, []db.Action{action})
+	if err != nil {
+		return db.Action{}, err
+	}
+	return actions[0], nil
+}
+
+// RenderActions fills in placeholders with what fields says the reader can see of the character
+func (s *TemplateService) RenderActions(character db.Character, fields db.CharacterReveleadFields, actions []db.Action) ([]db.Action, error) {
+	vars, err := s.variables(character, fields)
+	if err != nil {
+		return []db.Action{}, err
+	}
+	rendered := make([]db.Action, len(actions))
+	for i, action := range actions {
+		action.Content = renderTemplate(action.Content, vars)
+		rendered[i] = action
+	}
+	return rendered, nil
+}
+
+// variables only has the character fields that are revealed, hidden ones stay as their placeholder
+// so an action can't give away what the character doesn't
+func (s *TemplateService) variables(character db.Character, fields db.CharacterReveleadFields) (map[string]string, error) {
+	vars := map[string]string{}
+	revealed := map[string]bool{
+		"name":        fields.Name,
+		"race":        fields.Race,
+		"gender":      fields.Gender,
+		"age":         fields.Age,
+		"description": fields.Description,
+		"appearance":  fields.Appearance,
+	}
+	values := map[string]string{
+		"name":        character.Name,
+		"race":        character.Race,
+		"gender":      character.Gender,
+		"age":         character.Age,
+		"description": character.Description,
+		"appearance":  character.Appearance,
+	}
+	for field, value := range values {
+		if revealed[field] {
+			vars["character."+field] = value
+		}
+	}
+	if character.PlayerId != nil {
+		player, err := s.db.Player.Get(*character.PlayerId)
+		if err != nil {
+			slog.Error("error getting player to render action", "error", err, "playerId", *character.PlayerId)
+			return nil, err
+		}
+		vars["player.id"] = strconv.Itoa(player.Id)
+		vars["player.name"] = player.Name
+	}
+	variables, err := s.GetVariables()
+	if err != nil {
+		return nil, err
+	}
+	for _, variable := range variables {
+		vars["campaign."+variable.Name] = variable.Value
+	}
+	return vars, nil
+}
+
+// renderTemplate replaces {{name}} placeholders, unknown names are left alone so typos are visible.
+func renderTemplate(content string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
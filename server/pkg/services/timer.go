@@ -0,0 +1,247 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+	"github.com/justintoman/npc-surprise/pkg/stream"
+)
+
+type CreateTimerInput struct {
+	Label          string `json:"label"`
+	Target         string `json:"target" binding:"required,oneof=player character table"`
+	PlayerId       int    `json:"playerId"`
+	CharacterId    int    `json:"characterId"`
+	Seconds        int    `json:"seconds" binding:"required,gt=0,lte=86400"`
+	RevealActionId *int   `json:"revealActionId"`
+}
+
+type runningTimer struct {
+	stream.Timer
+	// who gets the timer's messages, fixed when it's created
+	recipients []int
+	expire     *time.Timer
+}
+
+// TimerService owns the countdowns so every client agrees on when time is up.
+// Timers are kept in memory, a restart forgets them.
+type TimerService struct {
+	db        db.Db
+	stream    stream.StreamingServer
+	actions   ActionService
+	templates TemplateService
+	spectator SpectatorService
+
+	mu     sync.Mutex
+	lastId int
+	timers map[int]*runningTimer
+}
+
+func NewTimerService(db db.Db, stream stream.StreamingServer, actions ActionService, spectator SpectatorService) *TimerService {
+	return &TimerService{
+		db:        db,
+		stream:    stream,
+		actions:   actions,
+		templates: NewTemplateService(db),
+		spectator: spectator,
+		timers:    make(map[int]*runningTimer),
+	}
+}
+
+func (s *TimerService) Create(input CreateTimerInput) (stream.Timer, error) {
+	recipients, err := s.recipients(input)
+	if err != nil {
+		return stream.Timer{}, err
+	}
+	if input.RevealActionId != nil {
+		_, err := s.db.Action.Get(*input.RevealActionId)
+		if err != nil {
+			slog.Error("Error getting action for timer", "error", err, "actionId", *input.RevealActionId)
+			return stream.Timer{}, err
+		}
+	}
+
+	s.mu.Lock()
+	s.lastId++
+	duration := time.Duration(input.Seconds) * time.Second
+	timer := &runningTimer{
+		Timer: stream.Timer{
+			Id:             s.lastId,
+			Label:          input.Label,
+			Target:         input.Target,
+			PlayerId:       input.PlayerId,
+			CharacterId:    input.CharacterId,
+			DurationMs:     duration.Milliseconds(),
+			RemainingMs:    duration.Milliseconds(),
+			RevealActionId: input.RevealActionId,
+		},
+		recipients: recipients,
+	}
+	s.timers[timer.Id] = timer
+	s.start(timer)
+	started := *timer
+	s.mu.Unlock()
+
+	s.stream.SendTimerMessage("timer-start", started.recipients, started.Timer)
+	return started.Timer, nil
+}
+
+func (s *TimerService) Pause(id int) (stream.Timer, error) {
+	s.mu.Lock()
+	timer, ok := s.timers[id]
+	if !ok {
+		s.mu.Unlock()
+		return stream.Timer{}, fmt.Errorf("timer %d not found", id)
+	}
+	if timer.State != stream.TimerRunning {
+		s.mu.Unlock()
+		return timer.Timer, nil
+	}
+	timer.expire.Stop()
+	timer.RemainingMs = max(0, time.Until(time.UnixMilli(timer.EndsAt)).Milliseconds())
+	timer.EndsAt = 0
+	timer.State = stream.TimerPaused
+	paused := *timer
+	s.mu.Unlock()
+
+	s.stream.SendTimerMessage("timer-pause", paused.recipients, paused.Timer)
+	return paused.Timer, nil
+}
+
+func (s *TimerService) Resume(id int) (stream.Timer, error) {
+	s.mu.Lock()
+	timer, ok := s.timers[id]
+	if !ok {
+		s.mu.Unlock()
+		return stream.Timer{}, fmt.Errorf("timer %d not found", id)
+	}
+	if timer.State != stream.TimerPaused {
+		s.mu.Unlock()
+		return timer.Timer, nil
+	}
+	s.start(timer)
+	resumed := *timer
+	s.mu.Unlock()
+
+	s.stream.SendTimerMessage("timer-resume", resumed.recipients, resumed.Timer)
+	return resumed.Timer, nil
+}
+
+// Cancel stops a timer without it expiring, so nothing gets revealed
+func (s *TimerService) Cancel(id int) error {
+	s.mu.Lock()
+	timer, ok := s.timers[id]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("timer %d not found", id)
+	}
+	if timer.expire != nil {
+		timer.expire.Stop()
+	}
+	delete(s.timers, id)
+	s.mu.Unlock()
+
+	s.stream.SendTimerMessage("timer-cancel", timer.recipients, timer.Timer)
+	return nil
+}
+
+// GetAll returns the timers that haven't expired, for the admin
+func (s *TimerService) GetAll() []stream.Timer {
+	return s.filter(func(*runningTimer) bool { return true })
+}
+
+// GetAllForPlayer returns the timers a player should be seeing count down
+func (s *TimerService) GetAllForPlayer(playerId int) []stream.Timer {
+	return s.filter(func(timer *runningTimer) bool {
+		for _, id := range timer.recipients {
+			if id == playerId || id == stream.AllPlayersId {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (s *TimerService) filter(keep func(*runningTimer) bool) []stream.Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timers := make([]stream.Timer, 0, len(s.timers))
+	for _, timer := range s.timers {
+		if keep(timer) {
+			timers = append(timers, timer.Timer)
+		}
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		return timers[i].Id < timers[j].Id
+	})
+	return timers
+}
+
+// start runs the timer for its remaining time, the lock must be held
+func (s *TimerService) start(timer *runningTimer) {
+	remaining := time.Duration(timer.RemainingMs) * time.Millisecond
+	timer.State = stream.TimerRunning
+	timer.EndsAt = time.Now().Add(remaining).UnixMilli()
+	id := timer.Id
+	timer.expire = time.AfterFunc(remaining, func() {
+		s.expire(id)
+	})
+}
+
+func (s *TimerService) expire(id int) {
+	s.mu.Lock()
+	timer, ok := s.timers[id]
+	if !ok || timer.State != stream.TimerRunning {
+		// paused or cancelled just as it ran out
+		s.mu.Unlock()
+		return
+	}
+	delete(s.timers, id)
+	timer.State = stream.TimerExpired
+	timer.RemainingMs = 0
+	s.mu.Unlock()
+
+	s.stream.SendTimerMessage("timer-expire", timer.recipients, timer.Timer)
+	if timer.RevealActionId == nil {
+		return
+	}
+	playerId, action, err := s.actions.Reveal(*timer.RevealActionId)
+	if err != nil || playerId == 0 {
+		return
+	}
+	rendered, err := s.templates.RenderAction(action)
+	if err != nil {
+		return
+	}
+	s.stream.SendPlayerActionMessage(playerId, action, rendered)
+	s.spectator.Sync(action.CharacterId)
+}
+
+func (s *TimerService) recipients(input CreateTimerInput) ([]int, error) {
+	switch input.Target {
+	case stream.TimerTargetPlayer:
+		_, err := s.db.Player.Get(input.PlayerId)
+		if err != nil {
+			slog.Error("Error getting player for timer", "error", err, "playerId", input.PlayerId)
+			return nil, err
+		}
+		return []int{input.PlayerId}, nil
+	case stream.TimerTargetCharacter:
+		character, err := s.db.Character.Get(input.CharacterId)
+		if err != nil {
+			slog.Error("Error getting character for timer", "error", err, "characterId", input.CharacterId)
+			return nil, err
+		}
+		if character.PlayerId == nil {
+			return nil, fmt.Errorf("character isn't assigned to a player")
+		}
+		return []int{*character.PlayerId}, nil
+	case stream.TimerTargetTable:
+		return []int{stream.AllPlayersId}, nil
+	}
+	return nil, fmt.Errorf("unknown timer target %q", input.Target)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+const apiTokenPrefix = "npc_"
+
+// last used is only written this often, so a bot hammering the api doesn't write on every request
+const apiTokenLastUsedResolution = time.Minute
+
+type ApiTokenService struct {
+	db db.Db
+}
+
+func NewApiTokenService(db db.Db) ApiTokenService {
+	return ApiTokenService{
+		db: db,
+	}
+}
+
+// CreatedApiToken is only returned once, only a hash of the token is stored
+type CreatedApiToken struct {
+	db.ApiToken `json:",inline"`
+	Token       string `json:"token"`
+}
+
+func (s *ApiTokenService) GetAll() ([]db.ApiToken, error) {
+	tokens, err := s.db.ApiToken.GetAll()
+	if err != nil {
+		slog.Error("Error fetching api tokens", "error", err)
+		return []db.ApiToken{}, err
+	}
+	return tokens, nil
+}
+
+func (s *ApiTokenService) Create(input db.CreateApiTokenPayload, adminId int) (CreatedApiToken, error) {
+	if input.ExpiresAt != nil && *input.ExpiresAt <= time.Now().Unix() {
+		return CreatedApiToken{}, fmt.Errorf("the token would already be expired")
+	}
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return CreatedApiToken{}, err
+	}
+	created := CreatedApiToken{Token: apiTokenPrefix + hex.EncodeToString(random)}
+	created.ApiToken, err = s.db.ApiToken.Create(input, adminId, hashApiToken(created.Token))
+	if err != nil {
+		slog.Error("Error creating api token", "error", err)
+		return CreatedApiToken{}, err
+	}
+	return created, nil
+}
+
+// Authenticate finds the token, checks it hasn't expired and records that it was used
+func (s *ApiTokenService) Authenticate(token string) (db.ApiToken, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return db.ApiToken{}, fmt.Errorf("not an api token")
+	}
+	apiToken, err := s.db.ApiToken.GetByHash(hashApiToken(token))
+	if err != nil {
+		return db.ApiToken{}, fmt.Errorf("unknown api token")
+	}
+	now := time.Now()
+	if apiToken.ExpiresAt != nil && now.Unix() >= *apiToken.ExpiresAt {
+		return db.ApiToken{}, fmt.Errorf("api token expired")
+	}
+	if apiToken.LastUsedAt == nil || now.Sub(time.Unix(*apiToken.LastUsedAt, 0)) >= apiTokenLastUsedResolution {
+		lastUsedAt := now.Unix()
+		err = s.db.ApiToken.SetLastUsedAt(apiToken.Id, lastUsedAt)
+		if err != nil {
+			slog.Error("Error recording api token use", "error", err, "tokenId", apiToken.Id)
+		}
+		apiToken.LastUsedAt = &lastUsedAt
+	}
+	return apiToken, nil
+}
+
+// Revoke deletes a token, owners can revoke any token and everyone else only their own
+func (s *ApiTokenService) Revoke(id int, by db.Admin) error {
+	if !by.Role.Can(db.AdminRoleOwner) {
+		tokens, err := s.GetAll()
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if token.Id == id && token.AdminId != by.Id {
+				return fmt.Errorf("only an owner can revoke someone else's token")
+			}
+		}
+	}
+	err := s.db.ApiToken.Delete(id)
+	if err != nil {
+		slog.Error("Error deleting api token", "error", err, "tokenId", id)
+		return err
+	}
+	return nil
+}
+
+// tokens are long and random, a plain hash is enough
+func hashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
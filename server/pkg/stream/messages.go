@@ -2,12 +2,55 @@ package stream
 
 import (
 	"log/slog"
+	"sync/atomic"
+	"time"
 
 	"github.com/justintoman/npc-surprise/pkg/db"
 )
 
+// messages sent to this id go to every connected admin
 const AdminPlayerId = 0
 
+// messages sent to this id go to every connected player, but not the admin
+const AllPlayersId = -1
+
+// messages sent to this id go to every connected spectator
+const AllSpectatorsId = -2
+
+// spectators aren't in the db, they get ids counting down from here for as long as they're connected
+const firstSpectatorId = -10
+
+// admin accounts connect with ids counting down from here, so each admin can be told apart from the others
+const firstAdminId = -1_000_000_000
+
+var lastSpectatorId atomic.Int64
+
+func init() {
+	lastSpectatorId.Store(firstSpectatorId + 1)
+}
+
+func NewSpectatorId() int {
+	return int(lastSpectatorId.Add(-1))
+}
+
+func IsSpectator(id int) bool {
+	return id <= firstSpectatorId && id > firstAdminId
+}
+
+// AdminStreamId is the id an admin account connects and gets messages with
+func AdminStreamId(adminId int) int {
+	return firstAdminId - adminId
+}
+
+// AdminId is the admin account behind an admin stream id
+func AdminId(id int) int {
+	return firstAdminId - id
+}
+
+func IsAdmin(id int) bool {
+	return id <= firstAdminId
+}
+
 type CharacterMessage struct {
 	Type string                  `json:"type" validate:"required,eq=character"`
 	Data db.CharacterWithActions `json:"data" validate:"required"`
@@ -18,14 +61,26 @@ type ActionMessage struct {
 	Data db.Action `json:"data" validate:"required"`
 }
 
+// Messages for players and spectators only hold the player types, which can't carry GM notes
+
+type PlayerCharacterMessage struct {
+	Type string                        `json:"type" validate:"required,eq=character"`
+	Data db.PlayerCharacterWithActions `json:"data" validate:"required"`
+}
+
+type PlayerActionMessage struct {
+	Type string          `json:"type" validate:"required,eq=action"`
+	Data db.PlayerAction `json:"data" validate:"required"`
+}
+
 type DeleteMessage struct {
-	Type string `json:"type" validate:"required,oneof=delete-action delete-character delete-player"`
-	Data int    `json:"data" validate:"required"` // player, character, action id
+	Type string `json:"type" validate:"required,oneof=delete-action delete-character delete-player delete-handout delete-table-character delete-admin"`
+	Data int    `json:"data" validate:"required"` // player, character, action, admin id
 }
 
 type InitPlayerMessage struct {
-	Type string                    `json:"type" validate:"required,eq=init-player"`
-	Data []db.CharacterWithActions `json:"data" validate:"required"`
+	Type string                          `json:"type" validate:"required,eq=init-player"`
+	Data []db.PlayerCharacterWithActions `json:"data" validate:"required"`
 }
 
 type InitAdminMessage struct {
@@ -34,15 +89,141 @@ type InitAdminMessage struct {
 }
 
 type InitAdminMessageData struct {
+	// what the connecting admin is allowed to do
+	Role   db.AdminRole `json:"role" validate:"required"`
+	Admins []db.Admin   `json:"admins" validate:"required"`
+	// empty when the lobby is closed
+	JoinCode   string                       `json:"joinCode"`
 	Players    []PlayerWithStatus           `json:"players" validate:"required"`
 	Characters []db.CharacterWithActions    `json:"characters" validate:"required"`
 	Fields     []db.CharacterReveleadFields `json:"fields" validate:"required"`
+	Handouts   []db.Handout                 `json:"handouts" validate:"required"`
+	// what spectators are allowed to see once revealed
+	PublicFields []db.CharacterReveleadFields `json:"publicFields" validate:"required"`
 }
 
-type PlayerWithStatus struct {
-	Id       int    `json:"id" validate:"required"`
+type HandoutMessage struct {
+	Type string     `json:"type" validate:"required,eq=handout"`
+	Data db.Handout `json:"data" validate:"required"`
+}
+
+type InitHandoutsMessage struct {
+	Type string       `json:"type" validate:"required,eq=init-handouts"`
+	Data []db.Handout `json:"data" validate:"required"`
+}
+
+type BatchMessage struct {
+	Type string           `json:"type" validate:"required,eq=batch"`
+	Data BatchMessageData `json:"data" validate:"required"`
+}
+
+// everything that changed for one player in a single batch of reveals
+type BatchMessageData struct {
+	Actions        []db.PlayerAction               `json:"actions" validate:"required"`
+	DeletedActions []int                           `json:"deletedActions" validate:"required"`
+	Characters     []db.PlayerCharacterWithActions `json:"characters" validate:"required"`
+}
+
+type InitSpectatorMessage struct {
+	Type string                          `json:"type" validate:"required,eq=init-spectator"`
+	Data []db.PlayerCharacterWithActions `json:"data" validate:"required"`
+}
+
+type PollMessage struct {
+	Type string  `json:"type" validate:"required,oneof=poll poll-tally poll-result"`
+	Data db.Poll `json:"data" validate:"required"`
+}
+
+type InitPollsMessage struct {
+	Type string    `json:"type" validate:"required,eq=init-polls"`
+	Data []db.Poll `json:"data" validate:"required"`
+}
+
+type PlayerScore struct {
+	PlayerId int    `json:"playerId" validate:"required"`
 	Name     string `json:"name" validate:"required"`
-	IsOnline bool   `json:"isOnline" validate:"required"`
+	Points   int    `json:"points" validate:"required"`
+}
+
+type ScoreboardMessage struct {
+	Type string         `json:"type" validate:"required,eq=scores"`
+	Data ScoreboardData `json:"data" validate:"required"`
+}
+
+type ScoreboardData struct {
+	Totals  []PlayerScore   `json:"totals" validate:"required"`
+	Entries []db.ScoreEntry `json:"entries" validate:"required"`
+}
+
+type LeaderboardMessage struct {
+	Type string        `json:"type" validate:"required,eq=leaderboard"`
+	Data []PlayerScore `json:"data" validate:"required"`
+}
+
+const (
+	TimerTargetPlayer    = "player"
+	TimerTargetCharacter = "character"
+	TimerTargetTable     = "table"
+
+	TimerRunning = "running"
+	TimerPaused  = "paused"
+	TimerExpired = "expired"
+)
+
+// Timers live in memory on the server, clients count down from EndsAt
+// and use ServerTime on each message to correct for their own clock.
+type Timer struct {
+	Id          int    `json:"id" validate:"required"`
+	Label       string `json:"label"`
+	Target      string `json:"target" validate:"required,oneof=player character table"`
+	PlayerId    int    `json:"playerId,omitempty"`
+	CharacterId int    `json:"characterId,omitempty"`
+	State       string `json:"state" validate:"required,oneof=running paused expired"`
+	// total length of the timer
+	DurationMs int64 `json:"durationMs" validate:"required"`
+	// time left when paused, or when it was last started or resumed
+	RemainingMs int64 `json:"remainingMs" validate:"required"`
+	// unix ms when a running timer expires
+	EndsAt int64 `json:"endsAt,omitempty"`
+	// action to reveal when the timer runs out
+	RevealActionId *int `json:"revealActionId,omitempty"`
+}
+
+type TimerMessage struct {
+	Type string           `json:"type" validate:"required,oneof=timer-start timer-pause timer-resume timer-expire timer-cancel"`
+	Data TimerMessageData `json:"data" validate:"required"`
+}
+
+type TimerMessageData struct {
+	Timer      Timer `json:"timer" validate:"required"`
+	ServerTime int64 `json:"serverTime" validate:"required"`
+}
+
+type InitTimersMessage struct {
+	Type string                `json:"type" validate:"required,eq=init-timers"`
+	Data InitTimersMessageData `json:"data" validate:"required"`
+}
+
+type InitTimersMessageData struct {
+	Timers     []Timer `json:"timers" validate:"required"`
+	ServerTime int64   `json:"serverTime" validate:"required"`
+}
+
+type TableMessage struct {
+	Type string               `json:"type" validate:"required,eq=table"`
+	Data []db.PlayerCharacter `json:"data" validate:"required"`
+}
+
+type TableCharacterMessage struct {
+	Type string             `json:"type" validate:"required,eq=table-character"`
+	Data db.PlayerCharacter `json:"data" validate:"required"`
+}
+
+type PlayerWithStatus struct {
+	Id       int             `json:"id" validate:"required"`
+	Name     string          `json:"name" validate:"required"`
+	IsOnline bool            `json:"isOnline" validate:"required"`
+	Status   db.PlayerStatus `json:"status,omitempty"`
 }
 
 type PlayerConnectedMessage struct {
@@ -55,6 +236,38 @@ type PlayerDisconnectedMessage struct {
 	Data int    `json:"data" validate:"required"` // player id
 }
 
+type PresenceStatus string
+
+const (
+	PresenceActive PresenceStatus = "active"
+	PresenceIdle   PresenceStatus = "idle"
+	PresenceAway   PresenceStatus = "away"
+)
+
+type PlayerPresenceMessage struct {
+	Type string         `json:"type" validate:"required,eq=player-presence"`
+	Data PlayerPresence `json:"data" validate:"required"`
+}
+
+type PlayerPresence struct {
+	PlayerId int            `json:"playerId" validate:"required"`
+	Status   PresenceStatus `json:"status" validate:"required,oneof=active idle away"`
+}
+
+type PlayerReplyMessage struct {
+	Type string      `json:"type" validate:"required,eq=player-reply"`
+	Data PlayerReply `json:"data" validate:"required"`
+}
+
+// PlayerReply is something a player says to the GMs over their websocket
+type PlayerReply struct {
+	PlayerId int    `json:"playerId" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Text     string `json:"text" validate:"required"`
+	// unix ms
+	At int64 `json:"at" validate:"required"`
+}
+
 /****************************************
 *********** Player Messages *************
 *****************************************/
@@ -62,7 +275,7 @@ type PlayerDisconnectedMessage struct {
 func (stream *EventStream) SendInitPlayerMessage(playerId int, characters []db.CharacterWithActions) {
 	payload := InitPlayerMessage{
 		Type: "init-player",
-		Data: characters,
+		Data: forPlayer(characters),
 	}
 	stream.sendMessage(playerId, payload)
 }
@@ -72,20 +285,23 @@ func (stream *EventStream) SendPlayerCharacterMessage(character db.CharacterWith
 		slog.Info("unabled to reveal character because character not assigned to a player", "characterId", character.Id)
 		return
 	}
-	stream.sendMessage(*character.PlayerId, CharacterMessage{
+	stream.sendMessage(*character.PlayerId, PlayerCharacterMessage{
 		Type: "character",
-		Data: character,
+		Data: character.ForPlayer(),
 	})
 }
 
-// since actions are either completely revealed or hidden, send to both admin and player
-func (stream *EventStream) SendPlayerActionMessage(playerId int, action db.Action) {
-	payload := ActionMessage{
+// since actions are either completely revealed or hidden, send to both admin and player.
+// The admin gets the raw template and the player gets the rendered action.
+func (stream *EventStream) SendPlayerActionMessage(playerId int, action db.Action, rendered db.Action) {
+	stream.sendAdminMessage(ActionMessage{
 		Type: "action",
 		Data: action,
-	}
-	stream.sendAdminMessage(payload)
-	stream.sendMessage(playerId, payload)
+	})
+	stream.sendMessage(playerId, PlayerActionMessage{
+		Type: "action",
+		Data: rendered.ForPlayer(),
+	})
 }
 
 func (stream *EventStream) SendHideActionMessage(playerId int, action db.Action) {
@@ -114,14 +330,194 @@ func (stream *EventStream) SendHideCharacterMessage(playerId int, character db.C
 	})
 }
 
+func (stream *EventStream) SendInitHandoutsMessage(playerId int, handouts []db.Handout) {
+	stream.sendMessage(playerId, InitHandoutsMessage{
+		Type: "init-handouts",
+		Data: handouts,
+	})
+}
+
+// Send a handout to the admin and the players who were just given access to it.
+// Pass AllPlayersId to send it to the whole table.
+func (stream *EventStream) SendRevealHandoutMessage(playerIds []int, handout db.Handout) {
+	stream.sendAdminMessage(HandoutMessage{
+		Type: "handout",
+		Data: handout,
+	})
+
+	// players don't get to know who else can see it
+	handout.PlayerIds = nil
+	for _, playerId := range playerIds {
+		stream.sendMessage(playerId, HandoutMessage{
+			Type: "handout",
+			Data: handout,
+		})
+	}
+}
+
+func (stream *EventStream) SendHideHandoutMessage(playerIds []int, handout db.Handout) {
+	stream.sendAdminMessage(HandoutMessage{
+		Type: "handout",
+		Data: handout,
+	})
+
+	// from the player's perspective, the handout was deleted
+	for _, playerId := range playerIds {
+		stream.sendMessage(playerId, DeleteMessage{
+			Type: "delete-handout",
+			Data: handout.Id,
+		})
+	}
+}
+
+// The table view lists the public fields of every character, including the ones other players voice
+func (stream *EventStream) SendTableMessage(playerId int, characters []db.Character) {
+	table := make([]db.PlayerCharacter, len(characters))
+	for i, character := range characters {
+		table[i] = character.ForPlayer()
+	}
+	stream.sendMessage(playerId, TableMessage{
+		Type: "table",
+		Data: table,
+	})
+}
+
+func (stream *EventStream) SendTableCharacterMessage(character db.Character) {
+	stream.sendMessage(AllPlayersId, TableCharacterMessage{
+		Type: "table-character",
+		Data: character.ForPlayer(),
+	})
+}
+
+func (stream *EventStream) SendTableDeleteCharacterMessage(characterId int) {
+	stream.sendMessage(AllPlayersId, DeleteMessage{
+		Type: "delete-table-character",
+		Data: characterId,
+	})
+}
+
+/****************************************
+********* Spectator Messages ************
+*****************************************/
+
+func (stream *EventStream) SendInitSpectatorMessage(spectatorId int, characters []db.CharacterWithActions) {
+	stream.sendMessage(spectatorId, InitSpectatorMessage{
+		Type: "init-spectator",
+		Data: forPlayer(characters),
+	})
+}
+
+// Send the public view of a character to every spectator
+func (stream *EventStream) SendSpectatorCharacterMessage(character db.CharacterWithActions) {
+	stream.sendMessage(AllSpectatorsId, PlayerCharacterMessage{
+		Type: "character",
+		Data: character.ForPlayer(),
+	})
+}
+
+func (stream *EventStream) SendSpectatorDeleteCharacterMessage(characterId int) {
+	stream.sendMessage(AllSpectatorsId, DeleteMessage{
+		Type: "delete-character",
+		Data: characterId,
+	})
+}
+
+/****************************************
+************ Poll Messages **************
+*****************************************/
+
+func (stream *EventStream) SendInitPollsMessage(id int, polls []db.Poll) {
+	stream.sendMessage(id, InitPollsMessage{
+		Type: "init-polls",
+		Data: polls,
+	})
+}
+
+// Open a poll for the recipients, they don't get to see the tally while voting
+func (stream *EventStream) SendPollMessage(recipients []int, poll db.Poll) {
+	stream.sendAdminMessage(PollMessage{
+		Type: "poll",
+		Data: poll,
+	})
+	poll.Tally = nil
+	for _, id := range recipients {
+		stream.sendMessage(id, PollMessage{
+			Type: "poll",
+			Data: poll,
+		})
+	}
+}
+
+// Live tallies only go to the admin
+func (stream *EventStream) SendPollTallyMessage(poll db.Poll) {
+	stream.sendAdminMessage(PollMessage{
+		Type: "poll-tally",
+		Data: poll,
+	})
+}
+
+func (stream *EventStream) SendPollResultMessage(recipients []int, poll db.Poll) {
+	payload := PollMessage{
+		Type: "poll-result",
+		Data: poll,
+	}
+	stream.sendAdminMessage(payload)
+	for _, id := range recipients {
+		stream.sendMessage(id, payload)
+	}
+}
+
+/****************************************
+*********** Timer Messages **************
+*****************************************/
+
+func (stream *EventStream) SendInitTimersMessage(id int, timers []Timer) {
+	stream.sendMessage(id, InitTimersMessage{
+		Type: "init-timers",
+		Data: InitTimersMessageData{
+			Timers:     timers,
+			ServerTime: time.Now().UnixMilli(),
+		},
+	})
+}
+
+// Send a timer event to the admin and whoever the timer is for
+func (stream *EventStream) SendTimerMessage(event string, recipients []int, timer Timer) {
+	payload := TimerMessage{
+		Type: event,
+		Data: TimerMessageData{
+			Timer:      timer,
+			ServerTime: time.Now().UnixMilli(),
+		},
+	}
+	stream.sendAdminMessage(payload)
+	for _, id := range recipients {
+		stream.sendMessage(id, payload)
+	}
+}
+
+func forPlayer(characters []db.CharacterWithActions) []db.PlayerCharacterWithActions {
+	players := make([]db.PlayerCharacterWithActions, len(characters))
+	for i, character := range characters {
+		players[i] = character.ForPlayer()
+	}
+	return players
+}
+
 /****************************************
 *********** Admin Messages *************
 *****************************************/
 
 func (stream *EventStream) SendInitAdminMessage(
+	adminId int,
+	role db.AdminRole,
+	admins []db.Admin,
+	joinCode string,
 	players []db.Player,
 	characters []db.CharacterWithActions,
 	fields []db.CharacterReveleadFields,
+	handouts []db.Handout,
+	publicFields []db.CharacterReveleadFields,
 ) {
 	connectedPlayers := stream.GetClients()
 	playersWithStatus := make([]PlayerWithStatus, 0)
@@ -134,6 +530,7 @@ func (stream *EventStream) SendInitAdminMessage(
 			Id:       player.Id,
 			Name:     player.Name,
 			IsOnline: false,
+			Status:   player.Status,
 		}
 		for _, connectedPlayer := range connectedPlayers {
 			if connectedPlayer.Id == p.Id {
@@ -144,12 +541,17 @@ func (stream *EventStream) SendInitAdminMessage(
 		playersWithStatus = append(playersWithStatus, p)
 	}
 
-	stream.sendAdminMessage(InitAdminMessage{
+	stream.sendMessage(adminId, InitAdminMessage{
 		Type: "init-admin",
 		Data: InitAdminMessageData{
-			Players:    playersWithStatus,
-			Characters: characters,
-			Fields:     fields,
+			Role:         role,
+			Admins:       admins,
+			JoinCode:     joinCode,
+			Players:      playersWithStatus,
+			Characters:   characters,
+			Fields:       fields,
+			Handouts:     handouts,
+			PublicFields: publicFields,
 		},
 	})
 }
@@ -164,6 +566,30 @@ type AdminCharacterMessageData struct {
 	Fields    db.CharacterReveleadFields `json:"fields" validate:"required"`
 }
 
+type AdminBatchMessage struct {
+	Type string                `json:"type" validate:"required,eq=batch"`
+	Data AdminBatchMessageData `json:"data" validate:"required"`
+}
+
+type AdminBatchMessageData struct {
+	Actions    []db.Action                 `json:"actions" validate:"required"`
+	Characters []AdminCharacterMessageData `json:"characters" validate:"required"`
+}
+
+// Send one message to the admin and one to each affected player so the whole batch lands together
+func (stream *EventStream) SendBatchMessages(admin AdminBatchMessageData, players map[int]BatchMessageData) {
+	stream.sendAdminMessage(AdminBatchMessage{
+		Type: "batch",
+		Data: admin,
+	})
+	for playerId, data := range players {
+		stream.sendMessage(playerId, BatchMessage{
+			Type: "batch",
+			Data: data,
+		})
+	}
+}
+
 func (stream *EventStream) SendAdminCharacterMessage(character db.CharacterWithActions) {
 	stream.sendAdminMessage(CharacterMessage{
 		Type: "character",
@@ -181,6 +607,18 @@ func (stream *EventStream) SendAdminCharacterMessageWithFields(character db.Char
 	})
 }
 
+type AdminPublicFieldsMessage struct {
+	Type string                     `json:"type" validate:"required,eq=public-fields"`
+	Data db.CharacterReveleadFields `json:"data" validate:"required"`
+}
+
+func (stream *EventStream) SendAdminPublicFieldsMessage(fields db.CharacterReveleadFields) {
+	stream.sendAdminMessage(AdminPublicFieldsMessage{
+		Type: "public-fields",
+		Data: fields,
+	})
+}
+
 func (stream *EventStream) SendAdminActionMessage(action db.Action) {
 	stream.sendAdminMessage(ActionMessage{
 		Type: "action",
@@ -188,6 +626,74 @@ func (stream *EventStream) SendAdminActionMessage(action db.Action) {
 	})
 }
 
+type AdminActivityMessage struct {
+	Type string        `json:"type" validate:"required,eq=admin-activity"`
+	Data AdminActivity `json:"data" validate:"required"`
+}
+
+// AdminActivity says which admin changed something, so every GM can see who did what
+type AdminActivity struct {
+	AdminId int    `json:"adminId" validate:"required"`
+	Name    string `json:"name" validate:"required"`
+	Method  string `json:"method" validate:"required"`
+	Path    string `json:"path" validate:"required"`
+	// name of the api token it was done with, if it wasn't the admin themselves
+	Token string `json:"token,omitempty"`
+	// unix ms
+	At int64 `json:"at" validate:"required"`
+}
+
+type AdminMessage struct {
+	Type string   `json:"type" validate:"required,eq=admin"`
+	Data db.Admin `json:"data" validate:"required"`
+}
+
+func (stream *EventStream) SendAdminActivityMessage(activity AdminActivity) {
+	stream.sendAdminMessage(AdminActivityMessage{
+		Type: "admin-activity",
+		Data: activity,
+	})
+}
+
+func (stream *EventStream) SendAdminAccountMessage(admin db.Admin) {
+	stream.sendAdminMessage(AdminMessage{
+		Type: "admin",
+		Data: admin,
+	})
+}
+
+func (stream *EventStream) SendDeleteAdminMessage(adminId int) {
+	stream.sendAdminMessage(DeleteMessage{
+		Type: "delete-admin",
+		Data: adminId,
+	})
+}
+
+type PlayerMessage struct {
+	Type string    `json:"type" validate:"required,eq=player"`
+	Data db.Player `json:"data" validate:"required"`
+}
+
+type JoinCodeMessage struct {
+	Type string `json:"type" validate:"required,eq=join-code"`
+	Data string `json:"data"` // empty when the lobby is closed
+}
+
+// SendPlayerMessage tells the admins about a player joining the lobby, or being approved or rejected
+func (stream *EventStream) SendPlayerMessage(player db.Player) {
+	stream.sendAdminMessage(PlayerMessage{
+		Type: "player",
+		Data: player,
+	})
+}
+
+func (stream *EventStream) SendJoinCodeMessage(code string) {
+	stream.sendAdminMessage(JoinCodeMessage{
+		Type: "join-code",
+		Data: code,
+	})
+}
+
 func (stream *EventStream) SendPlayerConnectedMessage(player db.Player) {
 	stream.sendAdminMessage(PlayerConnectedMessage{
 		Type: "player-connected",
@@ -202,6 +708,23 @@ func (stream *EventStream) SendPlayerDisconnectedMessage(playerId int) {
 	})
 }
 
+func (stream *EventStream) SendPlayerPresenceMessage(playerId int, status PresenceStatus) {
+	stream.sendAdminMessage(PlayerPresenceMessage{
+		Type: "player-presence",
+		Data: PlayerPresence{
+			PlayerId: playerId,
+			Status:   status,
+		},
+	})
+}
+
+func (stream *EventStream) SendPlayerReplyMessage(reply PlayerReply) {
+	stream.sendAdminMessage(PlayerReplyMessage{
+		Type: "player-reply",
+		Data: reply,
+	})
+}
+
 func (stream *EventStream) SendDeleteCharacterMessage(id int) {
 	stream.sendAdminMessage(DeleteMessage{
 		Type: "delete-character",
@@ -216,6 +739,39 @@ func (stream *EventStream) SendDeleteActionMessage(id int) {
 	})
 }
 
+func (stream *EventStream) SendAdminHandoutMessage(handout db.Handout) {
+	stream.sendAdminMessage(HandoutMessage{
+		Type: "handout",
+		Data: handout,
+	})
+}
+
+func (stream *EventStream) SendDeleteHandoutMessage(id int) {
+	stream.sendAdminMessage(DeleteMessage{
+		Type: "delete-handout",
+		Data: id,
+	})
+	stream.sendMessage(AllPlayersId, DeleteMessage{
+		Type: "delete-handout",
+		Data: id,
+	})
+}
+
+func (stream *EventStream) SendScoreboardMessage(scoreboard ScoreboardData) {
+	stream.sendAdminMessage(ScoreboardMessage{
+		Type: "scores",
+		Data: scoreboard,
+	})
+}
+
+// Players only get the totals, the reasons are between the GM and the ledger
+func (stream *EventStream) SendLeaderboardMessage(totals []PlayerScore) {
+	stream.sendMessage(AllPlayersId, LeaderboardMessage{
+		Type: "leaderboard",
+		Data: totals,
+	})
+}
+
 func (stream *EventStream) SendDeletePlayerMessage(id int) {
 	stream.sendAdminMessage(DeleteMessage{
 		Type: "delete-player",
@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+// polls stay under the 30 seconds a lot of proxies give a response before they cut it off
+const maxPollTimeout = 25 * time.Second
+
+// a poll client that hasn't polled in this long after its last poll timed out is gone
+const pollLeaseGrace = 30 * time.Second
+const pollLease = maxPollTimeout + pollLeaseGrace
+const pollPruneEvery = 10 * time.Second
+
+// Long polling is for networks that break SSE and websockets.
+// A poll client stays registered with Listen between polls, so it gets the same messages as everyone else,
+// but its channel only wakes up a waiting poll. What a poll returns comes from the replay buffers,
+// so a response that never made it is sent again on the next poll with the same cursor.
+
+type PollInput struct {
+	// the id of the last event the client got, empty on the first poll
+	Cursor string `query:"cursor"`
+	// a random id for each tab, it's how polls find their client again
+	Client  string `query:"client" validate:"required,min=8,max=64"`
+	Timeout int    `query:"timeout" default:"25" validate:"gte=1,lte=25"`
+}
+
+type PollResponse struct {
+	// the client has to throw away what it has, the init messages come in the events
+	Reset  bool          `json:"reset"`
+	Events []ClientEvent `json:"events"`
+	// pass it back on the next poll
+	Cursor string `json:"cursor"`
+}
+
+type PollHandlerFunc func(*gin.Context, *PollInput) (PollResponse, error)
+
+// pollClient is a poll client's lease, only Listen touches it
+type pollClient struct {
+	client   ClientChan
+	lastPoll time.Time
+	removed  func()
+}
+
+type pollRequest struct {
+	key       string
+	player    db.Player
+	role      ClientRole
+	sessionId string
+	cursor    string
+	added     func(resumed bool)
+	removed   func()
+	resync    func()
+	reply     chan pollReply
+}
+
+type pollReply struct {
+	err    error
+	reset  bool
+	events []ClientEvent
+	cursor string
+	// gets something once there's a new event for the client, it's closed when the client is disconnected
+	wake chan Event
+}
+
+func (stream *EventStream) NewPollHandler(onAdded OnClientAddedFunc, onRemoved OnClientRemovedFunc, onResync OnClientResyncFunc) PollHandlerFunc {
+	return func(c *gin.Context, input *PollInput) (PollResponse, error) {
+		ctxPlayer, ok := c.Get("player")
+		if !ok {
+			return PollResponse{}, fmt.Errorf("player not found. Is Middleware applied?")
+		}
+		player := ctxPlayer.(db.Player)
+		request := pollRequest{
+			key:       input.Client,
+			player:    player,
+			role:      roleOf(player.Id),
+			sessionId: c.GetString("sessionId"),
+			cursor:    input.Cursor,
+			added:     func(resumed bool) { onAdded(player, resumed) },
+			removed:   func() { onRemoved(player) },
+			resync:    func() { onResync(player) },
+		}
+
+		response := PollResponse{Events: make([]ClientEvent, 0)}
+		timeout := time.NewTimer(time.Duration(input.Timeout) * time.Second)
+		defer timeout.Stop()
+		for {
+			request.reply = make(chan pollReply, 1)
+			stream.Polls <- request
+			reply := <-request.reply
+			if reply.err != nil {
+				return PollResponse{}, reply.err
+			}
+			response.Reset = response.Reset || reply.reset
+			response.Events = append(response.Events, reply.events...)
+			response.Cursor = reply.cursor
+			if len(response.Events) > 0 {
+				return response, nil
+			}
+
+			select {
+			case _, ok := <-reply.wake:
+				if !ok {
+					// disconnected, the next poll finds out why
+					return response, nil
+				}
+			case <-timeout.C:
+				return response, nil
+			case <-c.Request.Context().Done():
+				return response, nil
+			}
+			request.cursor = response.Cursor
+		}
+	}
+}
+
+// poll answers a poll with everything the client missed since its cursor, and registers the client if it's new
+func (stream *EventStream) poll(request pollRequest) pollReply {
+	poll, ok := stream.pollClients[request.key]
+	if ok && (poll.client.Role != request.role || (request.role != RoleSpectator && poll.client.Id != request.player.Id)) {
+		return pollReply{err: fmt.Errorf("that poll client belongs to someone else")}
+	}
+
+	id := request.player.Id
+	if ok {
+		// spectators get a new id with every request, they keep the one they started with
+		id = poll.client.Id
+	}
+	client := ClientChan{Player: db.Player{Id: id}, Role: request.role}
+	missed, resumed := stream.missed(client, request.cursor)
+
+	if !ok {
+		client = ClientChan{
+			Channel: make(chan Event, stream.config.QueueSize),
+			queue: &clientQueue{
+				resync:  request.resync,
+				pollKey: request.key,
+			},
+			Role:      request.role,
+			SessionId: request.sessionId,
+			Player: db.Player{
+				Id:   id,
+				Name: request.player.Name,
+			},
+		}
+		poll = &pollClient{
+			client:  client,
+			removed: request.removed,
+		}
+		stream.pollClients[request.key] = poll
+		stream.TotalClients[client] = true
+		slog.Info("Poll client added", "id", client.Id, "name", client.Name, "resumed", resumed)
+		go request.added(resumed)
+	} else if !resumed {
+		poll.client.queue.resyncs++
+		go request.resync()
+	}
+	poll.lastPoll = time.Now()
+
+	// everything that woke it up is in the replay buffers
+	for len(poll.client.Channel) > 0 {
+		select {
+		case <-poll.client.Channel:
+		default:
+		}
+	}
+
+	reply := pollReply{
+		reset:  !resumed,
+		events: make([]ClientEvent, 0, len(missed)),
+		cursor: stream.eventId(stream.lastSeq),
+		wake:   poll.client.Channel,
+	}
+	for _, event := range missed {
+		reply.events = append(reply.events, ClientEvent{Id: event.Id, Message: event.Payload})
+	}
+	return reply
+}
+
+// prunePolls forgets poll clients that stopped polling
+func (stream *EventStream) prunePolls() {
+	for _, poll := range stream.pollClients {
+		if time.Since(poll.lastPoll) > pollLease {
+			slog.Info("Poll client stopped polling", "id", poll.client.Id, "name", poll.client.Name)
+			stream.remove(poll.client)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is a message on its way to a client, with the id the client reconnects with
+type Event struct {
+	Id      string
+	Payload any
+}
+
+type replayEvent struct {
+	seq int64
+	Event
+}
+
+// replayBuffer keeps the last messages sent to one address, a player, admin or spectator id or one of the broadcast ids.
+// It keeps them whether anyone is connected or not, that's the point.
+type replayBuffer struct {
+	events []replayEvent
+	// the newest event that didn't fit anymore, a client that saw less than this missed something we can't replay
+	evicted int64
+	// when the last client with this id went away, zero while one is connected
+	idleSince time.Time
+}
+
+// buffers of players, admins and spectators nobody's been connected as for this long are thrown away.
+// Spectators get a new id every time they connect, so theirs would pile up otherwise.
+const replayIdle = 10 * time.Minute
+
+func (buffer *replayBuffer) add(event replayEvent, size int) {
+	buffer.events = append(buffer.events, event)
+	if len(buffer.events) > size {
+		buffer.evicted = buffer.events[0].seq
+		buffer.events = buffer.events[1:]
+	}
+}
+
+// record gives a message the next event id and keeps it for clients that reconnect
+func (stream *EventStream) record(msg Message) Event {
+	stream.lastSeq++
+	event := replayEvent{
+		seq: stream.lastSeq,
+		Event: Event{
+			Id:      stream.eventId(stream.lastSeq),
+			Payload: msg.Payload,
+		},
+	}
+	buffer, ok := stream.replay[msg.PlayerId]
+	if !ok {
+		// it might have been pruned, a client from before then missed whatever was in it
+		buffer = &replayBuffer{evicted: stream.prunedSeq}
+		stream.replay[msg.PlayerId] = buffer
+	}
+	buffer.add(event, stream.config.ReplaySize)
+	return event.Event
+}
+
+// the epoch keeps ids from before a restart from matching ours
+func (stream *EventStream) eventId(seq int64) string {
+	return fmt.Sprintf("%s.%d", stream.epoch, seq)
+}
+
+// resume sends a reconnecting client everything it missed since lastEventId.
+// It's false when the client has to start over from a fresh init instead.
+func (stream *EventStream) resume(client ClientChan, lastEventId string) bool {
+	missed, ok := stream.missed(client, lastEventId)
+	if !ok {
+		return false
+	}
+	// more than fits in the queue would only overflow it
+	if len(missed) > cap(client.Channel) {
+		return false
+	}
+	for _, event := range missed {
+		client.Channel <- event.Event
+	}
+	return true
+}
+
+// missed is everything sent to a client after lastEventId, oldest first.
+// It's false when the client is new, the server restarted or the buffers don't go back far enough.
+func (stream *EventStream) missed(client ClientChan, lastEventId string) ([]replayEvent, bool) {
+	epoch, seqString, ok := strings.Cut(lastEventId, ".")
+	if !ok || epoch != stream.epoch {
+		return nil, false
+	}
+	seq, err := strconv.ParseInt(seqString, 10, 64)
+	if err != nil || seq > stream.lastSeq {
+		return nil, false
+	}
+
+	missed := make([]replayEvent, 0)
+	for _, address := range client.addresses() {
+		buffer, ok := stream.replay[address]
+		if !ok {
+			// it might have been pruned with events the client never got
+			if seq < stream.prunedSeq {
+				return nil, false
+			}
+			continue
+		}
+		if buffer.evicted > seq {
+			return nil, false
+		}
+		for _, event := range buffer.events {
+			if event.seq > seq {
+				missed = append(missed, event)
+			}
+		}
+	}
+	sort.Slice(missed, func(i, j int) bool { return missed[i].seq < missed[j].seq })
+	return missed, true
+}
+
+// pruneReplay forgets the buffers of ids that haven't had a client for a while.
+// The broadcast buffers stay, every client uses them.
+func (stream *EventStream) pruneReplay() {
+	connected := make(map[int]bool, len(stream.TotalClients))
+	for client := range stream.TotalClients {
+		connected[client.Id] = true
+	}
+	now := time.Now()
+	for address, buffer := range stream.replay {
+		switch {
+		case address == AdminPlayerId || address == AllPlayersId || address == AllSpectatorsId:
+		case connected[address]:
+			buffer.idleSince = time.Time{}
+		case buffer.idleSince.IsZero():
+			buffer.idleSince = now
+		case now.Sub(buffer.idleSince) > replayIdle:
+			delete(stream.replay, address)
+			stream.prunedSeq = stream.lastSeq
+		}
+	}
+}
+
+// addresses are the ids a client gets messages for, it has to match receives
+func (client ClientChan) addresses() []int {
+	switch client.Role {
+	case RoleSpectator:
+		return []int{client.Id, AllSpectatorsId}
+	case RoleAdmin:
+		return []int{client.Id, AdminPlayerId}
+	default:
+		return []int{client.Id, AllPlayersId}
+	}
+}
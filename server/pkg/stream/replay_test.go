@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+func newTestStream() *EventStream {
+	return New(db.Db{}, Config{ReplaySize: 8}).(*EventStream)
+}
+
+func TestMissedReplaysWhatCameAfter(t *testing.T) {
+	stream := newTestStream()
+	client := ClientChan{Player: db.Player{Id: 5}, Role: RolePlayer}
+
+	first := stream.record(Message{PlayerId: 5, Payload: "first"})
+	stream.record(Message{PlayerId: 6, Payload: "someone else"})
+	second := stream.record(Message{PlayerId: AllPlayersId, Payload: "second"})
+
+	missed, ok := stream.missed(client, first.Id)
+	if !ok {
+		t.Fatal("expected to resume")
+	}
+	if len(missed) != 1 || missed[0].Id != second.Id {
+		t.Fatalf("expected only %s, got %v", second.Id, missed)
+	}
+}
+
+func TestMissedResetsAfterPruneAndNewEvent(t *testing.T) {
+	stream := newTestStream()
+	client := ClientChan{Player: db.Player{Id: 5}, Role: RolePlayer}
+
+	// the broadcast buffer stays, so only the player's own buffer can tell it missed something
+	stream.record(Message{PlayerId: AllPlayersId, Payload: "everyone"})
+	before := stream.record(Message{PlayerId: 5, Payload: "before"})
+	stream.record(Message{PlayerId: 5, Payload: "while asleep"})
+	stream.replay[5].idleSince = time.Now().Add(-replayIdle - time.Minute)
+	stream.pruneReplay()
+	if _, ok := stream.replay[5]; ok {
+		t.Fatal("expected the idle buffer to be pruned")
+	}
+
+	after := stream.record(Message{PlayerId: 5, Payload: "after"})
+	if _, ok := stream.missed(client, before.Id); ok {
+		t.Fatal("a client from before the prune has to start over")
+	}
+
+	// a client that connected after the prune is fine
+	stream.record(Message{PlayerId: 5, Payload: "later"})
+	missed, ok := stream.missed(client, after.Id)
+	if !ok {
+		t.Fatal("expected a client from after the prune to resume")
+	}
+	if len(missed) != 1 || missed[0].Payload != "later" {
+		t.Fatalf("expected only the later event, got %v", missed)
+	}
+}
+
+func TestMissedResetsAfterPruneWithoutNewEvent(t *testing.T) {
+	stream := newTestStream()
+	client := ClientChan{Player: db.Player{Id: 5}, Role: RolePlayer}
+
+	before := stream.record(Message{PlayerId: 5, Payload: "before"})
+	stream.record(Message{PlayerId: 5, Payload: "while asleep"})
+	stream.replay[5].idleSince = time.Now().Add(-replayIdle - time.Minute)
+	stream.pruneReplay()
+
+	if _, ok := stream.missed(client, before.Id); ok {
+		t.Fatal("a client from before the prune has to start over")
+	}
+}
@@ -3,10 +3,13 @@ package stream
 import (
 	"context"
 	"fmt"
-	"io"
 	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/justintoman/npc-surprise/pkg/db"
 )
 
@@ -14,10 +17,18 @@ import (
 // https://github.com/gin-gonic/examples/blob/master/server-sent-event/main.go
 
 type StreamingServer interface {
-	NewUserStream(onAdd OnClientAddedFunc, onRemove OnClientRemovedFunc) (gin.HandlerFunc, StreamHandlerFunc)
+	// the stream works over SSE, or a websocket when the request asks for one
+	NewUserStream(onAdd OnClientAddedFunc, onRemove OnClientRemovedFunc, onResync OnClientResyncFunc, onMessage OnClientMessageFunc) (gin.HandlerFunc, StreamHandlerFunc)
+	// for networks that break both, it gets the same messages a poll at a time
+	NewPollHandler(onAdd OnClientAddedFunc, onRemove OnClientRemovedFunc, onResync OnClientResyncFunc) PollHandlerFunc
 	Close(ClientChan)
+	// close every connection of a player or admin, or just the one from a session.
+	// Pass AllPlayersId to disconnect every player.
+	Disconnect(playerId int)
+	DisconnectSession(sessionId string)
 	Listen(context.Context)
 	GetClients() []db.Player
+	GetQueueStats() []ClientQueueStats
 
 	// player messages
 
@@ -26,28 +37,101 @@ type StreamingServer interface {
 	// Send a redacted character to only the assigned player.
 	// Note that admins need a full non-redacted character, so this only sends to the player.
 	SendPlayerCharacterMessage(charcter db.CharacterWithActions)
-	SendPlayerActionMessage(playerId int, action db.Action)
+	SendPlayerActionMessage(playerId int, action db.Action, rendered db.Action)
 	SendHideActionMessage(playerId int, action db.Action)
 	SendHideCharacterMessage(playerId int, character db.CharacterWithActions)
+	SendInitHandoutsMessage(playerId int, handouts []db.Handout)
+	SendRevealHandoutMessage(playerIds []int, handout db.Handout)
+	SendHideHandoutMessage(playerIds []int, handout db.Handout)
+	SendTableMessage(playerId int, characters []db.Character)
+	SendTableCharacterMessage(character db.Character)
+	SendTableDeleteCharacterMessage(characterId int)
 
 	// admin messages
-	SendInitAdminMessage(players []db.Player, characters []db.CharacterWithActions, fields []db.CharacterReveleadFields)
+	SendInitAdminMessage(adminId int, role db.AdminRole, admins []db.Admin, joinCode string, players []db.Player, characters []db.CharacterWithActions, fields []db.CharacterReveleadFields, handouts []db.Handout, publicFields []db.CharacterReveleadFields)
 	SendAdminCharacterMessage(character db.CharacterWithActions)
 	SendAdminCharacterMessageWithFields(character db.CharacterWithActions, fields db.CharacterReveleadFields)
 	SendAdminActionMessage(action db.Action)
+	SendPlayerMessage(player db.Player)
+	SendJoinCodeMessage(code string)
 	SendPlayerConnectedMessage(player db.Player)
 	SendPlayerDisconnectedMessage(playerId int)
+	SendPlayerPresenceMessage(playerId int, status PresenceStatus)
+	SendPlayerReplyMessage(reply PlayerReply)
 	SendDeleteCharacterMessage(characterId int)
 	SendDeleteActionMessage(actionId int)
 	SendDeletePlayerMessage(playerId int)
+	SendAdminHandoutMessage(handout db.Handout)
+	SendDeleteHandoutMessage(handoutId int)
+	SendBatchMessages(admin AdminBatchMessageData, players map[int]BatchMessageData)
+	SendAdminPublicFieldsMessage(fields db.CharacterReveleadFields)
+	SendScoreboardMessage(scoreboard ScoreboardData)
+	SendLeaderboardMessage(totals []PlayerScore)
+	SendAdminActivityMessage(activity AdminActivity)
+	SendAdminAccountMessage(admin db.Admin)
+	SendDeleteAdminMessage(adminId int)
+
+	// poll messages
+	SendInitPollsMessage(id int, polls []db.Poll)
+	SendPollMessage(recipients []int, poll db.Poll)
+	SendPollTallyMessage(poll db.Poll)
+	SendPollResultMessage(recipients []int, poll db.Poll)
+
+	// timer messages
+	SendInitTimersMessage(id int, timers []Timer)
+	SendTimerMessage(event string, recipients []int, timer Timer)
+
+	// spectator messages
+	SendInitSpectatorMessage(spectatorId int, characters []db.CharacterWithActions)
+	SendSpectatorCharacterMessage(character db.CharacterWithActions)
+	SendSpectatorDeleteCharacterMessage(characterId int)
 }
 
-func New(db db.Db) StreamingServer {
+type SlowClientPolicy string
+
+const (
+	// drop everything the client hasn't read yet and send it a fresh init
+	SlowClientResync SlowClientPolicy = "resync"
+	// close the stream, the browser reconnects on its own and gets a fresh init
+	SlowClientDisconnect SlowClientPolicy = "disconnect"
+)
+
+type Config struct {
+	// how many messages a client can fall behind before it counts as slow
+	QueueSize   int
+	SlowClients SlowClientPolicy
+	// how many messages per address are kept for clients that reconnect
+	ReplaySize int
+}
+
+const DefaultQueueSize = 256
+const DefaultReplaySize = 256
+
+// a client that's still too slow this soon after a resync is disconnected instead
+const resyncCooldown = 30 * time.Second
+
+func New(db db.Db, config Config) StreamingServer {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultQueueSize
+	}
+	if config.SlowClients == "" {
+		config.SlowClients = SlowClientResync
+	}
+	if config.ReplaySize <= 0 {
+		config.ReplaySize = DefaultReplaySize
+	}
 	eventStream := &EventStream{
-		Message:       make(chan Message),
-		NewClients:    make(chan ClientChan),
+		config:        config,
+		epoch:         strconv.FormatInt(time.Now().UnixNano(), 36),
+		replay:        make(map[int]*replayBuffer),
+		Message:       make(chan Message, config.QueueSize),
+		NewClients:    make(chan newClient),
 		ClosedClients: make(chan ClientChan),
+		Disconnects:   make(chan disconnect),
+		QueueStats:    make(chan chan []ClientQueueStats),
+		Polls:         make(chan pollRequest),
 		TotalClients:  make(map[ClientChan]bool),
+		pollClients:   make(map[string]*pollClient),
 	}
 	return eventStream
 }
@@ -56,32 +140,140 @@ func (stream *EventStream) Close(clientChan ClientChan) {
 	stream.ClosedClients <- clientChan
 }
 
+func (stream *EventStream) Disconnect(playerId int) {
+	stream.Disconnects <- disconnect{playerId: playerId}
+}
+
+func (stream *EventStream) DisconnectSession(sessionId string) {
+	if sessionId == "" {
+		return
+	}
+	stream.Disconnects <- disconnect{sessionId: sessionId}
+}
+
 func (stream *EventStream) sendMessage(playerId int, message any) {
 	slog.Info("Sending message", "clientId", playerId)
 	stream.Message <- Message{PlayerId: playerId, Payload: message}
 }
 
+// sendAdminMessage fans out to every connected admin
 func (stream *EventStream) sendAdminMessage(message any) {
-	for client := range stream.TotalClients {
-		if client.Id == AdminPlayerId {
-			stream.sendMessage(client.Id, message)
-		}
-	}
-
 	stream.sendMessage(AdminPlayerId, message)
 }
 
+// GetClients returns the connected players and admins, spectators aren't players
 func (stream *EventStream) GetClients() []db.Player {
 	clients := make([]db.Player, 0, len(stream.TotalClients))
 	for client := range stream.TotalClients {
+		if client.Role == RoleSpectator {
+			continue
+		}
 		clients = append(clients, client.Player)
 	}
 	return clients
 }
 
+// GetQueueStats says how far behind each client is
+func (stream *EventStream) GetQueueStats() []ClientQueueStats {
+	reply := make(chan []ClientQueueStats, 1)
+	stream.QueueStats <- reply
+	return <-reply
+}
+
+type ClientRole string
+
+const (
+	RoleAdmin     ClientRole = "admin"
+	RolePlayer    ClientRole = "player"
+	RoleSpectator ClientRole = "spectator"
+)
+
+func roleOf(id int) ClientRole {
+	if IsAdmin(id) {
+		return RoleAdmin
+	}
+	if IsSpectator(id) {
+		return RoleSpectator
+	}
+	return RolePlayer
+}
+
 type ClientChan struct {
 	db.Player
-	Channel chan any
+	Role ClientRole
+	// the session the client connected with, empty for spectators
+	SessionId string
+	// buffered, Listen never waits on a client that isn't reading
+	Channel chan Event
+	queue   *clientQueue
+}
+
+// clientQueue is what Listen keeps track of about a client's queue. Only Listen touches it.
+type clientQueue struct {
+	dropped    int
+	resyncs    int
+	lastResync time.Time
+	resync     func()
+	// the last event id the client said it handled, only websockets ack
+	acked atomic.Value
+	// set for long polling clients, it's how their polls find them
+	pollKey string
+}
+
+func (queue *clientQueue) ackedEventId() string {
+	eventId, _ := queue.acked.Load().(string)
+	return eventId
+}
+
+type ClientQueueStats struct {
+	Id   int        `json:"id"`
+	Name string     `json:"name"`
+	Role ClientRole `json:"role"`
+	// messages waiting to be written to the client
+	Depth    int `json:"depth"`
+	Capacity int `json:"capacity"`
+	// messages thrown away because the client fell behind
+	Dropped int `json:"dropped"`
+	Resyncs int `json:"resyncs"`
+	// the last event id the client said it handled, only websocket clients ack
+	Acked string `json:"acked,omitempty"`
+}
+
+// disconnect picks clients by session if it has one, otherwise by player
+type disconnect struct {
+	playerId  int
+	sessionId string
+}
+
+func (d disconnect) matches(client ClientChan) bool {
+	if d.sessionId != "" {
+		return client.SessionId == d.sessionId
+	}
+	if d.playerId == AllPlayersId {
+		return client.Role == RolePlayer
+	}
+	return client.Id == d.playerId
+}
+
+// receives decides which clients a message fans out to.
+// Spectators only ever get messages addressed to them or to all spectators,
+// so nothing meant for a player can reach the audience.
+func (client ClientChan) receives(msg Message) bool {
+	switch client.Role {
+	case RoleSpectator:
+		return msg.PlayerId == client.Id || msg.PlayerId == AllSpectatorsId
+	case RoleAdmin:
+		return msg.PlayerId == client.Id || msg.PlayerId == AdminPlayerId
+	default:
+		return msg.PlayerId == client.Id || msg.PlayerId == AllPlayersId
+	}
+}
+
+// newClient is a client connecting, Listen answers whether it picked up where it left off
+type newClient struct {
+	client      ClientChan
+	lastEventId string
+	resumed     chan bool
 }
 
 type Message struct {
@@ -91,64 +283,161 @@ type Message struct {
 
 type EventStream struct {
 	Db            db.Db
+	config        Config
 	Message       chan Message
-	NewClients    chan ClientChan
+	NewClients    chan newClient
 	ClosedClients chan ClientChan
+	Disconnects   chan disconnect
+	QueueStats    chan chan []ClientQueueStats
+	Polls         chan pollRequest
 	// map of clients by string topic
 	TotalClients map[ClientChan]bool
+	// only Listen touches these
+	epoch   string
+	lastSeq int64
+	replay  map[int]*replayBuffer
+	// lastSeq when a buffer was last pruned, a client from before then can't tell what it lost
+	prunedSeq   int64
+	pollClients map[string]*pollClient
 }
 
 func (stream *EventStream) Listen(c context.Context) {
+	prune := time.NewTicker(pollPruneEvery)
+	defer prune.Stop()
 	for {
 		select {
 		// Add new available client
-		case client := <-stream.NewClients:
+		case request := <-stream.NewClients:
+			client := request.client
 			stream.TotalClients[client] = true
 			names := make([]string, 0)
 			for client := range stream.TotalClients {
 				names = append(names, client.Name)
 			}
 			slog.Info(fmt.Sprintf("Client added. %d registered clients. Clients: %+v", len(stream.TotalClients), names))
+			resumed := stream.resume(client, request.lastEventId)
+			if resumed {
+				slog.Info("Client resumed", "id", client.Id, "name", client.Name, "lastEventId", request.lastEventId)
+			}
+			request.resumed <- resumed
 
 		// Remove closed client
 		case client := <-stream.ClosedClients:
 			slog.Info("Client closed", "id", client.Id, "name", client.Name)
-			delete(stream.TotalClients, client)
-			close(client.Channel)
+			// it's already gone if it was disconnected
+			if stream.TotalClients[client] {
+				stream.remove(client)
+			}
 			slog.Info(fmt.Sprintf("Removed client. %d registered clients", len(stream.TotalClients)))
 
+		// Kick clients, closing the channel ends their stream
+		case disconnect := <-stream.Disconnects:
+			for client := range stream.TotalClients {
+				if disconnect.matches(client) {
+					slog.Info("Disconnecting client", "id", client.Id, "name", client.Name)
+					stream.remove(client)
+				}
+			}
+
 		// Broadcast message to client
 		case eventMsg := <-stream.Message:
+			event := stream.record(eventMsg)
 			sentMessage := false
 			for client := range stream.TotalClients {
-				if client.Id == eventMsg.PlayerId {
+				if client.receives(eventMsg) {
 					sentMessage = true
-					client.Channel <- eventMsg.Payload
+					stream.deliver(client, event)
 				}
 			}
-			if !sentMessage {
+			if !sentMessage && eventMsg.PlayerId != AllPlayersId && eventMsg.PlayerId != AllSpectatorsId && eventMsg.PlayerId != AdminPlayerId {
 				slog.Error(fmt.Sprintf("Attempted to send message to a client that doesn't exist. Id: %d", eventMsg.PlayerId))
 				continue
 			}
 
+		case reply := <-stream.QueueStats:
+			stats := make([]ClientQueueStats, 0, len(stream.TotalClients))
+			for client := range stream.TotalClients {
+				stats = append(stats, ClientQueueStats{
+					Id:       client.Id,
+					Name:     client.Name,
+					Role:     client.Role,
+					Depth:    len(client.Channel),
+					Capacity: cap(client.Channel),
+					Dropped:  client.queue.dropped,
+					Resyncs:  client.queue.resyncs,
+					Acked:    client.queue.ackedEventId(),
+				})
+			}
+			reply <- stats
+
+		case request := <-stream.Polls:
+			request.reply <- stream.poll(request)
+
+		case <-prune.C:
+			stream.prunePolls()
+			stream.pruneReplay()
+
 		case <-c.Done():
 			return
 		}
 	}
 }
 
+// deliver queues a message for a client without waiting on it.
+// A client with a full queue is too far behind to catch up message by message,
+// so it either starts over from a fresh init or gets disconnected.
+func (stream *EventStream) deliver(client ClientChan, event Event) {
+	select {
+	case client.Channel <- event:
+		return
+	default:
+	}
+
+	queue := client.queue
+	queue.dropped++
+	if stream.config.SlowClients == SlowClientDisconnect || time.Since(queue.lastResync) < resyncCooldown {
+		slog.Warn("Disconnecting slow client", "id", client.Id, "name", client.Name, "dropped", queue.dropped)
+		stream.remove(client)
+		return
+	}
+
+	// nothing in the queue matters once the client gets a fresh init
+	for len(client.Channel) > 0 {
+		select {
+		case <-client.Channel:
+			queue.dropped++
+		default:
+		}
+	}
+	queue.resyncs++
+	queue.lastResync = time.Now()
+	slog.Warn("Client fell behind, resyncing", "id", client.Id, "name", client.Name, "dropped", queue.dropped)
+	// the init messages go through Listen, so they can't be sent from here
+	go queue.resync()
+}
+
+// remove forgets a client, closing the channel ends its stream
+func (stream *EventStream) remove(client ClientChan) {
+	delete(stream.TotalClients, client)
+	close(client.Channel)
+	// a poll client has no connection to notice it's gone
+	if poll, ok := stream.pollClients[client.queue.pollKey]; ok && poll.client == client {
+		delete(stream.pollClients, client.queue.pollKey)
+		go poll.removed()
+	}
+}
+
 type StreamHandlerFunc func(*gin.Context) (bool, error)
 
-type OnClientAddedFunc func(db.Player)
+// OnClientAddedFunc is told whether the client resumed, otherwise it needs a fresh init
+type OnClientAddedFunc func(player db.Player, resumed bool)
 type OnClientRemovedFunc func(db.Player)
 
-func (stream *EventStream) NewUserStream(onAdded OnClientAddedFunc, onRemoved OnClientRemovedFunc) (gin.HandlerFunc, StreamHandlerFunc) {
-	middleware := func(c *gin.Context) {
-		c.Writer.Header().Set("Content-Type", "text/event-stream")
-		c.Writer.Header().Set("Cache-Control", "no-cache")
-		c.Writer.Header().Set("Connection", "keep-alive")
-		c.Writer.Header().Set("Transfer-Encoding", "chunked")
+// OnClientResyncFunc sends a client everything it needs to start over, after its queue overflowed
+type OnClientResyncFunc func(db.Player)
 
+func (stream *EventStream) NewUserStream(onAdded OnClientAddedFunc, onRemoved OnClientRemovedFunc, onResync OnClientResyncFunc, onMessage OnClientMessageFunc) (gin.HandlerFunc, StreamHandlerFunc) {
+	middleware := func(c *gin.Context) {
 		ctxPlayer, ok := c.Get("player")
 		if !ok {
 			slog.Info("Player not found. Is Middleware applied?")
@@ -157,27 +446,57 @@ func (stream *EventStream) NewUserStream(onAdded OnClientAddedFunc, onRemoved On
 		}
 
 		player := ctxPlayer.(db.Player)
-
-		slog.Info("Player connected, creating client", "id", player.Id, "name", player.Name)
+		role := roleOf(player.Id)
 
 		clientChan := ClientChan{
-			Channel: make(chan any),
+			Channel: make(chan Event, stream.config.QueueSize),
+			queue: &clientQueue{
+				resync: func() { onResync(player) },
+			},
+			Role:      role,
+			SessionId: c.GetString("sessionId"),
 			Player: db.Player{
 				Id:   player.Id,
 				Name: player.Name,
 			},
 		}
-		stream.NewClients <- clientChan
 
-		go onAdded(player)
+		// EventSource sends the id of the last message it got when it reconnects,
+		// a websocket can't set headers so it has to put it in the query
+		lastEventId := c.GetHeader("Last-Event-ID")
+		var transport clientTransport
+		if websocket.IsWebSocketUpgrade(c.Request) {
+			ws, err := newWebSocketTransport(c, clientChan, player, onMessage)
+			if err != nil {
+				slog.Info("Websocket upgrade failed", "error", err, "id", player.Id)
+				c.AbortWithStatusJSON(400, gin.H{"message": "Couldn't open a websocket.", "status": 400})
+				return
+			}
+			transport = ws
+			lastEventId = c.Query("lastEventId")
+		} else {
+			transport = newSSETransport(c)
+		}
+
+		slog.Info("Player connected, creating client", "id", player.Id, "name", player.Name, "role", role)
+
+		resumed := make(chan bool, 1)
+		stream.NewClients <- newClient{
+			client:      clientChan,
+			lastEventId: lastEventId,
+			resumed:     resumed,
+		}
+
+		go onAdded(player, <-resumed)
 
 		go func() {
-			<-c.Writer.CloseNotify()
+			<-transport.done()
 			stream.Close(clientChan)
 			go onRemoved(player)
 		}()
 
 		c.Set("clientChan", clientChan)
+		c.Set("transport", transport)
 		c.Next()
 	}
 	handler := func(c *gin.Context) (bool, error) {
@@ -189,14 +508,14 @@ func (stream *EventStream) NewUserStream(onAdded OnClientAddedFunc, onRemoved On
 		if !ok {
 			return false, fmt.Errorf("clientChan not a ClientChan. Is Middleware applied?")
 		}
-		c.Stream(func(w io.Writer) bool {
-			// Stream message to client from message channel
-			if msg, ok := <-clientChan.Channel; ok {
-				c.SSEvent("message", msg)
-				return true
+		transport := c.MustGet("transport").(clientTransport)
+		// Stream messages to the client until it's gone or disconnected
+		for event := range clientChan.Channel {
+			if !transport.send(event) {
+				break
 			}
-			return false
-		})
+		}
+		transport.close()
 		return true, nil
 	}
 	return middleware, handler
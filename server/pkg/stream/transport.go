@@ -0,0 +1,48 @@
+package stream
+
+import (
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// clientTransport is how events get to a client. Listen doesn't know or care which one a client uses.
+type clientTransport interface {
+	// send writes an event, false once the client can't take any more
+	send(Event) bool
+	// done is closed once the client is gone
+	done() <-chan struct{}
+	// close hangs up once the client has been disconnected
+	close()
+}
+
+// sseTransport is the default, a text/event-stream response that EventSource reconnects on its own
+type sseTransport struct {
+	c *gin.Context
+}
+
+func newSSETransport(c *gin.Context) sseTransport {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("Transfer-Encoding", "chunked")
+	// a resumed client might not get anything for a while, it should still know it's connected
+	c.Writer.Flush()
+	return sseTransport{c: c}
+}
+
+func (t sseTransport) send(event Event) bool {
+	t.c.Render(-1, sse.Event{
+		Id:    event.Id,
+		Event: "message",
+		Data:  event.Payload,
+	})
+	t.c.Writer.Flush()
+	return t.c.Request.Context().Err() == nil
+}
+
+func (t sseTransport) done() <-chan struct{} {
+	return t.c.Request.Context().Done()
+}
+
+// the response ends when the handler returns
+func (t sseTransport) close() {}
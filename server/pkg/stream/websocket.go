@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/justintoman/npc-surprise/pkg/db"
+)
+
+const (
+	webSocketWriteWait = 10 * time.Second
+	// the browser answers pings on its own, a client that doesn't is gone
+	webSocketPongWait  = 60 * time.Second
+	webSocketPingEvery = webSocketPongWait * 9 / 10
+	// clients only send small things like acks and replies
+	webSocketMaxMessageSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// CheckOrigin is left as the default, which only lets pages from our own host connect.
+	// The session cookie comes along with any site's websocket, so that check is all that stops other sites.
+
+	// the middleware answers with the usual json error instead
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {},
+}
+
+// ClientMessage is something a client sends back over a websocket
+type ClientMessage struct {
+	Type string          `json:"type" validate:"required,oneof=ack reply presence"`
+	Data json.RawMessage `json:"data"`
+}
+
+// ClientEvent is one message to a websocket or polling client, the id is what it picks up from after reconnecting
+type ClientEvent struct {
+	Id      string `json:"id"`
+	Message any    `json:"message"`
+}
+
+type OnClientMessageFunc func(player db.Player, message ClientMessage)
+
+// webSocketTransport carries the same messages as the event stream, and whatever the client sends back
+type webSocketTransport struct {
+	conn   *websocket.Conn
+	closed chan struct{}
+}
+
+func newWebSocketTransport(c *gin.Context, client ClientChan, player db.Player, onMessage OnClientMessageFunc) (*webSocketTransport, error) {
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return nil, err
+	}
+	t := &webSocketTransport{
+		conn:   conn,
+		closed: make(chan struct{}),
+	}
+	go t.read(client, player, onMessage)
+	go t.ping()
+	return t, nil
+}
+
+func (t *webSocketTransport) read(client ClientChan, player db.Player, onMessage OnClientMessageFunc) {
+	defer close(t.closed)
+	t.conn.SetReadLimit(webSocketMaxMessageSize)
+	t.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	t.conn.SetPongHandler(func(string) error {
+		return t.conn.SetReadDeadline(time.Now().Add(webSocketPongWait))
+	})
+	for {
+		_, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var message ClientMessage
+		err = json.Unmarshal(data, &message)
+		if err != nil {
+			slog.Info("Unreadable websocket message", "error", err, "id", client.Id)
+			continue
+		}
+		// acks are the stream's business, the rest is up to whoever set up the stream
+		if message.Type == "ack" {
+			var eventId string
+			json.Unmarshal(message.Data, &eventId)
+			client.queue.acked.Store(eventId)
+			continue
+		}
+		onMessage(player, message)
+	}
+}
+
+func (t *webSocketTransport) ping() {
+	ticker := time.NewTicker(webSocketPingEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := t.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(webSocketWriteWait))
+			if err != nil {
+				return
+			}
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+func (t *webSocketTransport) send(event Event) bool {
+	t.conn.SetWriteDeadline(time.Now().Add(webSocketWriteWait))
+	err := t.conn.WriteJSON(ClientEvent{
+		Id:      event.Id,
+		Message: event.Payload,
+	})
+	return err == nil
+}
+
+func (t *webSocketTransport) done() <-chan struct{} {
+	return t.closed
+}
+
+func (t *webSocketTransport) close() {
+	t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(webSocketWriteWait))
+	t.conn.Close()
+}
@@ -1,10 +1,10 @@
 import { useAtomValue } from 'jotai';
-import { PlusCircle, X } from 'lucide-react';
+import { Ban, Check, PlusCircle, X } from 'lucide-react';
 import { Link } from 'react-router-dom';
 import { NpcSurpriseApi } from '~/api';
 import { Character } from '~/components/Character';
 import { Button } from '~/components/ui/button';
-import { charactersAtom, playersAtom } from '~/state';
+import { charactersAtom, joinCodeAtom, playersAtom } from '~/state';
 
 export function AdminHome() {
   const characters = useAtomValue(charactersAtom);
@@ -30,7 +30,41 @@ export function AdminHome() {
           ))}
         </ul>
       </div>
-      <PlayersList />
+      <div className="space-y-8">
+        <Lobby />
+        <PlayersList />
+      </div>
+    </div>
+  );
+}
+
+function Lobby() {
+  const joinCode = useAtomValue(joinCodeAtom);
+
+  return (
+    <div className="space-y-4 rounded-sm">
+      <h2 className="text-lg font-bold">Lobby</h2>
+      {joinCode ? (
+        <p className="font-mono text-2xl tracking-widest">{joinCode}</p>
+      ) : (
+        <p className="text-sm text-muted-foreground">
+          Closed, nobody new can join.
+        </p>
+      )}
+      <div className="flex space-x-2">
+        <Button size="sm" onClick={() => NpcSurpriseApi.openLobby()}>
+          {joinCode ? 'New Code' : 'Open'}
+        </Button>
+        {joinCode ? (
+          <Button
+            size="sm"
+            variant="outline"
+            onClick={() => NpcSurpriseApi.closeLobby()}
+          >
+            Close
+          </Button>
+        ) : null}
+      </div>
     </div>
   );
 }
@@ -56,14 +90,41 @@ function PlayersList() {
               <span className="text-muted-foreground group-data-[online=true]:font-bold group-data-[online=true]:text-secondary-foreground">
                 {player.name}
               </span>
+              {player.status === 'pending' || player.status === 'rejected' ? (
+                <span className="text-xs italic text-muted-foreground">
+                  {player.status === 'pending' ? 'waiting' : 'rejected'}
+                </span>
+              ) : null}
+            </div>
+            <div className="flex space-x-1">
+              {player.status === 'pending' || player.status === 'rejected' ? (
+                <Button
+                  size="tiny"
+                  variant="outline"
+                  title="Let them in"
+                  onClick={() => NpcSurpriseApi.approvePlayer(player.id)}
+                >
+                  <Check className="h-3 w-3" />
+                </Button>
+              ) : null}
+              {player.status === 'pending' ? (
+                <Button
+                  size="tiny"
+                  variant="outline"
+                  title="Turn them away"
+                  onClick={() => NpcSurpriseApi.rejectPlayer(player.id)}
+                >
+                  <Ban className="h-3 w-3" />
+                </Button>
+              ) : null}
+              <Button
+                size="tiny"
+                variant="outline"
+                onClick={() => NpcSurpriseApi.deletePlayer(player.id)}
+              >
+                <X className="h-3 w-3" />
+              </Button>
             </div>
-            <Button
-              size="tiny"
-              variant="outline"
-              onClick={() => NpcSurpriseApi.deletePlayer(player.id)}
-            >
-              <X className="h-3 w-3" />
-            </Button>
           </li>
         ))}
       </ul>
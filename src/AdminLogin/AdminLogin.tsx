@@ -0,0 +1,85 @@
+import { zodResolver } from '@hookform/resolvers/zod';
+import { useForm } from 'react-hook-form';
+import { Link, useNavigate } from 'react-router-dom';
+import { z } from 'zod';
+import { errorMessage, NpcSurpriseApi } from '~/api';
+import { Button } from '~/components/ui/button';
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from '~/components/ui/form';
+import { Input } from '~/components/ui/input';
+
+export function AdminLogin() {
+  const methods = useForm<LoginForm>({
+    resolver: zodResolver(schema),
+    defaultValues: { name: '', password: '' },
+  });
+  const navigate = useNavigate();
+  async function submit({ name, password }: LoginForm) {
+    try {
+      await NpcSurpriseApi.adminLogin(name, password);
+      navigate('/admin');
+    } catch (error) {
+      console.error(error);
+      methods.setError('password', { message: await errorMessage(error) });
+    }
+  }
+
+  return (
+    <Form {...methods}>
+      <form onSubmit={methods.handleSubmit(submit)} className="space-y-4 p-4">
+        <FormField
+          control={methods.control}
+          name="name"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>GM Name</FormLabel>
+              <FormControl>
+                <Input {...field} autoComplete="username" />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <FormField
+          control={methods.control}
+          name="password"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>Password</FormLabel>
+              <FormControl>
+                <Input
+                  {...field}
+                  type="password"
+                  autoComplete="current-password"
+                />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <div className="flex items-center justify-between">
+          <Button type="submit">Log In</Button>
+          <Link
+            to="/admin/setup"
+            className="text-sm text-muted-foreground underline"
+          >
+            First time? Set up the GM account
+          </Link>
+        </div>
+      </form>
+    </Form>
+  );
+}
+
+const schema = z.object({
+  name: z.string().min(1, 'Name is required'),
+  password: z.string().min(1, 'Password is required'),
+});
+
+type LoginForm = z.infer<typeof schema>;
@@ -0,0 +1,98 @@
+import { zodResolver } from '@hookform/resolvers/zod';
+import { useForm } from 'react-hook-form';
+import { Link, useNavigate } from 'react-router-dom';
+import { z } from 'zod';
+import { errorMessage, NpcSurpriseApi } from '~/api';
+import { Button } from '~/components/ui/button';
+import {
+  Form,
+  FormControl,
+  FormField,
+  FormItem,
+  FormLabel,
+  FormMessage,
+} from '~/components/ui/form';
+import { Input } from '~/components/ui/input';
+
+// AdminSetup makes the owner account with the ADMIN_KEY the server was started with.
+// An owner who only ever logged in with the key sets their password here too.
+export function AdminSetup() {
+  const methods = useForm<SetupForm>({
+    resolver: zodResolver(schema),
+    defaultValues: { key: '', name: '', password: '' },
+  });
+  const navigate = useNavigate();
+  async function submit({ key, name, password }: SetupForm) {
+    try {
+      await NpcSurpriseApi.adminSetup(key, name, password);
+      navigate('/admin');
+    } catch (error) {
+      console.error(error);
+      methods.setError('key', { message: await errorMessage(error) });
+    }
+  }
+
+  return (
+    <Form {...methods}>
+      <form onSubmit={methods.handleSubmit(submit)} className="space-y-4 p-4">
+        <FormField
+          control={methods.control}
+          name="key"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>Admin Key</FormLabel>
+              <FormControl>
+                <Input {...field} type="password" autoComplete="off" />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <FormField
+          control={methods.control}
+          name="name"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>GM Name</FormLabel>
+              <FormControl>
+                <Input {...field} autoComplete="username" />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <FormField
+          control={methods.control}
+          name="password"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>Password</FormLabel>
+              <FormControl>
+                <Input {...field} type="password" autoComplete="new-password" />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <div className="flex items-center justify-between">
+          <Button type="submit">Set Up</Button>
+          <Link
+            to="/admin/login"
+            className="text-sm text-muted-foreground underline"
+          >
+            Already set up? Log in
+          </Link>
+        </div>
+      </form>
+    </Form>
+  );
+}
+
+const schema = z.object({
+  key: z.string().min(1, 'The admin key is required'),
+  name: z.string().min(1, 'Name is required'),
+  // the server wants at least this many
+  password: z.string().min(10, 'Passwords need at least 10 characters'),
+});
+
+type SetupForm = z.infer<typeof schema>;
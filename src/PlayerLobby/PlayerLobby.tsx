@@ -0,0 +1,54 @@
+import { useEffect, useState } from 'react';
+import { useLoaderData, useNavigate } from 'react-router-dom';
+import { isInLobby, NpcSurpriseApi, StatusResponse } from '~/api';
+
+// the lobby can't use the stream until the GM lets them in, so it just asks every so often
+const checkEvery = 3000;
+
+export function PlayerLobby() {
+  const initial = useLoaderData() as StatusResponse;
+  const [status, setStatus] = useState(initial);
+  const navigate = useNavigate();
+
+  useEffect(() => {
+    const interval = setInterval(async () => {
+      try {
+        const status = await NpcSurpriseApi.status();
+        if (!status.id) {
+          // the GM deleted them
+          navigate('/login');
+        } else if (!isInLobby(status)) {
+          navigate('/');
+        } else {
+          setStatus(status);
+        }
+      } catch (error) {
+        console.error(error);
+      }
+    }, checkEvery);
+    return () => clearInterval(interval);
+  }, [navigate]);
+
+  return (
+    <div className="space-y-6 p-4">
+      {status.status === 'rejected' ? (
+        <>
+          <p className="mb-10 text-center text-2xl">🚪 Not this time 🚪</p>
+          <p>The GM didn't let you in.</p>
+          <p className="text-sm text-muted-foreground">
+            If that's a mistake, tell them. You'll get in as soon as they
+            change their mind.
+          </p>
+        </>
+      ) : (
+        <>
+          <p className="mb-10 text-center text-2xl">⏳ Waiting for the GM ⏳</p>
+          <p>Hi {status.name}! The GM will let you in soon.</p>
+          <p className="text-sm text-muted-foreground">
+            Keep this page open, it'll take you to the game once you're in.
+          </p>
+        </>
+      )}
+    </div>
+  );
+}
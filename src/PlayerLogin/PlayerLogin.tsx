@@ -1,9 +1,9 @@
 import { zodResolver } from '@hookform/resolvers/zod';
 import { Check } from 'lucide-react';
 import { useForm } from 'react-hook-form';
-import { useNavigate } from 'react-router-dom';
+import { Link, useNavigate, useSearchParams } from 'react-router-dom';
 import { z } from 'zod';
-import { NpcSurpriseApi } from '~/api';
+import { errorMessage, isInLobby, NpcSurpriseApi } from '~/api';
 import { Button } from '~/components/ui/button';
 import {
   Form,
@@ -16,36 +16,57 @@ import {
 import { Input } from '~/components/ui/input';
 
 export function PlayerLogin({ name }: { name?: string }) {
+  const [searchParams] = useSearchParams();
   const methods = useForm<LoginForm>({
     resolver: zodResolver(schema),
-    defaultValues: { name },
+    defaultValues: { name, code: searchParams.get('code') ?? '' },
   });
   const navigate = useNavigate();
-  async function submit({ name }: LoginForm) {
+  async function submit({ name, code }: LoginForm) {
     try {
-      const status = await NpcSurpriseApi.login(name);
+      const status = await NpcSurpriseApi.login(name, code);
       if (status.isAdmin) {
         navigate('/admin');
+      } else if (isInLobby(status)) {
+        navigate('/lobby');
       } else {
         navigate('/');
       }
     } catch (error) {
       console.error(error);
+      methods.setError('code', { message: await errorMessage(error) });
     }
   }
 
   return (
     <Form {...methods}>
-      <form onSubmit={methods.handleSubmit(submit)} className="p-4">
+      <form onSubmit={methods.handleSubmit(submit)} className="space-y-4 p-4">
         <FormField
           control={methods.control}
           name="name"
           render={({ field }) => (
             <FormItem>
               <FormLabel>Player Name</FormLabel>
+              <FormControl>
+                <Input {...field} />
+              </FormControl>
+              <FormMessage />
+            </FormItem>
+          )}
+        />
+        <FormField
+          control={methods.control}
+          name="code"
+          render={({ field }) => (
+            <FormItem>
+              <FormLabel>Join Code</FormLabel>
               <div className="flex items-center space-x-2">
                 <FormControl>
-                  <Input {...field} />
+                  <Input
+                    {...field}
+                    autoCapitalize="characters"
+                    autoComplete="off"
+                  />
                 </FormControl>
                 <Button type="submit" size="icon" className="shrink-0">
                   <Check className="h-4 w-4" />
@@ -55,6 +76,12 @@ export function PlayerLogin({ name }: { name?: string }) {
             </FormItem>
           )}
         />
+        <p className="text-sm text-muted-foreground">
+          Running the game?{' '}
+          <Link to="/admin/login" className="underline">
+            GM login
+          </Link>
+        </p>
       </form>
     </Form>
   );
@@ -62,6 +89,8 @@ export function PlayerLogin({ name }: { name?: string }) {
 
 const schema = z.object({
   name: z.string().min(1, 'Name is required'),
+  // the GM reads it out, you only need it the first time
+  code: z.string().optional(),
 });
 
 type LoginForm = z.infer<typeof schema>;
@@ -1,16 +1,67 @@
-import ky from 'ky';
-import { Action, Character, CharacterRevealedFields } from '~/types';
+import ky, { HTTPError } from 'ky';
+import {
+  Action,
+  Character,
+  CharacterRevealedFields,
+  Player,
+  PlayerStatus,
+} from '~/types';
 
 const prefixUrl = import.meta.env.VITE_API_PREFIX;
 
+// the server hands out a CSRF token in a header and wants it back on anything that changes something
+const csrfHeader = 'X-CSRF-Token';
+const safeMethods = ['GET', 'HEAD', 'OPTIONS'];
+let csrfToken: string | null = null;
+
 const client = ky.create({
   prefixUrl,
+  hooks: {
+    beforeRequest: [
+      async (request) => {
+        if (safeMethods.includes(request.method)) {
+          return;
+        }
+        if (!csrfToken) {
+          const response = await ky
+            .get('csrf', { prefixUrl })
+            .json<{ token: string }>();
+          csrfToken = response.token;
+        }
+        request.headers.set(csrfHeader, csrfToken);
+      },
+    ],
+    afterResponse: [
+      (_request, _options, response) => {
+        const token = response.headers.get(csrfHeader);
+        if (token) {
+          csrfToken = token;
+        }
+      },
+    ],
+  },
 });
 
 export const NpcSurpriseApi = {
   // auth
-  login(name: string): Promise<Required<StatusResponse>> {
-    const response = client.post('login', { json: { name: name } });
+  // new players need the join code the GM reads out, returning players don't
+  login(name: string, code?: string): Promise<StatusResponse> {
+    const response = client.post('login', { json: { name, code } });
+    return response.json();
+  },
+  adminLogin(name: string, password: string): Promise<StatusResponse> {
+    const response = client.post('admin/login', { json: { name, password } });
+    return response.json();
+  },
+  // the ADMIN_KEY makes the first owner, or gives an owner who only had the key a password
+  adminSetup(
+    key: string,
+    name: string,
+    password: string,
+  ): Promise<StatusResponse> {
+    const response = client.post('admin/setup', {
+      json: { key, name, password },
+    });
     return response.json();
   },
   status(): Promise<StatusResponse> {
@@ -82,10 +133,54 @@ export const NpcSurpriseApi = {
   deletePlayer(playerId: number): Promise<void> {
     return client.delete(`players/${playerId}`).json();
   },
+
+  /**
+   * Lobby
+   */
+
+  approvePlayer(playerId: number) {
+    return client
+      .put(`players/${playerId}/approve`)
+      .json<Omit<Player, 'isOnline'>>();
+  },
+
+  rejectPlayer(playerId: number) {
+    return client
+      .put(`players/${playerId}/reject`)
+      .json<Omit<Player, 'isOnline'>>();
+  },
+
+  openLobby() {
+    return client.post('join-code').json<{ code: string }>();
+  },
+
+  closeLobby() {
+    return client.post('join-code/close');
+  },
 };
 
+// isInLobby is true for players the GM hasn't let in, or turned away
+export function isInLobby(status: StatusResponse) {
+  return status.status === 'pending' || status.status === 'rejected';
+}
+
+// errorMessage gets what the server said went wrong, if it said anything
+export async function errorMessage(error: unknown): Promise<string> {
+  if (error instanceof HTTPError) {
+    try {
+      const body = await error.response.json();
+      return body.message ?? body.error ?? error.message;
+    } catch {
+      return error.message;
+    }
+  }
+  return error instanceof Error ? error.message : 'Something went wrong';
+}
+
 export type StatusResponse = {
   isAdmin: boolean;
   id?: string;
   name?: string;
+  // players from before the lobby have none, they're in
+  status?: PlayerStatus;
 };
@@ -5,8 +5,11 @@ import { EditAction } from '~/Admin/EditAction';
 import { EditCharacter } from '~/Admin/EditCharacter';
 import { NewAction } from '~/Admin/NewAction';
 import { NewCharacter } from '~/Admin/NewCharacter';
-import { NpcSurpriseApi } from '~/api';
+import { AdminLogin } from '~/AdminLogin/AdminLogin';
+import { AdminSetup } from '~/AdminLogin/AdminSetup';
+import { isInLobby, NpcSurpriseApi } from '~/api';
 import { App } from '~/App';
+import { PlayerLobby } from '~/PlayerLobby/PlayerLobby';
 import { PlayerLogin } from '~/PlayerLogin/PlayerLogin';
 import { PlayerView } from '~/PlayerView/PlayerView';
 
@@ -31,6 +34,16 @@ export const router = createBrowserRouter([
         path: '/login',
         element: <PlayerLogin />,
       },
+      {
+        loader: adminLoginLoader,
+        path: '/admin/login',
+        element: <AdminLogin />,
+      },
+      {
+        loader: adminLoginLoader,
+        path: '/admin/setup',
+        element: <AdminSetup />,
+      },
       {
         async loader() {
           console.log('player loader');
@@ -39,6 +52,10 @@ export const router = createBrowserRouter([
             console.log('redirect to admin');
             return redirect('/admin');
           }
+          if (status.id && isInLobby(status)) {
+            console.log('redirect to lobby');
+            return redirect('/lobby');
+          }
           if (status.id) {
             return null;
           }
@@ -49,6 +66,28 @@ export const router = createBrowserRouter([
         path: '/',
         element: <PlayerView />,
       },
+      {
+        async loader() {
+          console.log('lobby loader');
+          const status = await NpcSurpriseApi.status();
+          if (status.isAdmin) {
+            console.log('redirect to admin');
+            return redirect('/admin');
+          }
+          if (status.id && !isInLobby(status)) {
+            console.log('redirect to player');
+            return redirect('/');
+          }
+          if (status.id) {
+            return status;
+          }
+
+          console.log('redirect to login');
+          return redirect('/login');
+        },
+        path: '/lobby',
+        element: <PlayerLobby />,
+      },
       {
         async loader() {
           console.log('admin loader');
@@ -61,8 +100,8 @@ export const router = createBrowserRouter([
             return redirect('/');
           }
 
-          console.log('redirect to login');
-          return redirect('/login');
+          console.log('redirect to admin login');
+          return redirect('/admin/login');
         },
         path: '/admin',
         element: <AdminPage />,
@@ -92,3 +131,14 @@ export const router = createBrowserRouter([
     ],
   },
 ]);
+
+// a GM who's already logged in goes straight to the admin page
+async function adminLoginLoader() {
+  console.log('admin login loader');
+  const status = await NpcSurpriseApi.status();
+  if (status.isAdmin) {
+    console.log('redirect to admin');
+    return redirect('/admin');
+  }
+  return null;
+}
@@ -52,12 +52,25 @@ type InitPlayerMessage = {
 type InitAdminMessage = {
   type: 'init-admin';
   data: {
+    // empty when the lobby is closed
+    joinCode: string;
     players: Player[];
     characters: Character[];
     fields: CharacterRevealedFields[];
   };
 };
 
+// a player joined the lobby, or the GM let them in or turned them away
+type PlayerMessage = {
+  type: 'player';
+  data: Omit<Player, 'isOnline'>;
+};
+
+type JoinCodeMessage = {
+  type: 'join-code';
+  data: string; // empty when the lobby is closed
+};
+
 type PlayerConnectedMessage = {
   type: 'player-connected';
   data: Player;
@@ -81,11 +94,14 @@ type Message =
   | ActionMessage
   | PlayerConnectedMessage
   | PlayerDisconnectedMessage
+  | PlayerMessage
+  | JoinCodeMessage
   | DeleteMessage;
 
 function handleEvents(message: Message) {
   switch (message.type) {
     case 'init-admin': {
+      store.set(joinCodeAtomInternal, message.data.joinCode);
       store.set(playersAtomInternal, message.data.players);
       store.set(charactersAtomInternal, message.data.characters);
       store.set(characterRevealedFieldsInternal, message.data.fields);
@@ -186,6 +202,24 @@ function handleEvents(message: Message) {
       break;
     }
 
+    case 'player': {
+      const players = store.get(playersAtomInternal);
+      const player = players.find((p) => p.id === message.data.id);
+      const updated = { isOnline: false, ...player, ...message.data };
+      store.set(
+        playersAtomInternal,
+        player
+          ? players.map((p) => (p.id === message.data.id ? updated : p))
+          : [...players, updated],
+      );
+      break;
+    }
+
+    case 'join-code': {
+      store.set(joinCodeAtomInternal, message.data);
+      break;
+    }
+
     case 'player-disconnected': {
       const players = store.get(playersAtomInternal);
       store.set(
@@ -244,6 +278,9 @@ export const playerAtomFamily = atomFamily((id: number | undefined) =>
   }),
 );
 
+const joinCodeAtomInternal = atom('');
+export const joinCodeAtom = atom((get) => get(joinCodeAtomInternal));
+
 const charactersAtomInternal = atom<Character[]>([]);
 export const charactersAtom = atom<Character[]>((get) =>
   get(charactersAtomInternal),
@@ -1,7 +1,10 @@
+export type PlayerStatus = 'pending' | 'approved' | 'rejected';
+
 export type Player = {
   id: number;
   name: string;
   isOnline: boolean;
+  status?: PlayerStatus;
 };
 
 export type CurrentPlayer = Omit<Player, 'isOnline'>;